@@ -4,10 +4,12 @@ import (
 	"log"
 	"net/http"
 	"os"
+	_ "time/tzdata"
 
 	"github.com/jagac/excelify/internal/converter"
 	"github.com/jagac/excelify/internal/logging"
 	"github.com/jagac/excelify/internal/server"
+	"github.com/jagac/excelify/internal/templates"
 	"github.com/joho/godotenv"
 )
 
@@ -30,7 +32,12 @@ func main() {
 	}
 	converter := converter.NewConverter()
 
-	handler := server.NewHandler(converter)
+	var opts []server.HandlerOption
+	if templateDir := os.Getenv("TEMPLATE_DIR"); templateDir != "" {
+		opts = append(opts, server.WithTemplateRegistry(templates.NewRegistry(templateDir)))
+	}
+
+	handler := server.NewHandler(converter, opts...)
 	router := server.NewRouter(handler, logger)
 	router.RegisterRoutes(mux)
 
@@ -0,0 +1,143 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jagac/excelify/internal/types"
+	"github.com/xuri/excelize/v2"
+)
+
+// chartTypes maps the chart types of a chart spec to excelize chart types.
+var chartTypes = map[string]excelize.ChartType{
+	"line":    excelize.Line,
+	"bar":     excelize.Bar,
+	"column":  excelize.Col,
+	"area":    excelize.Area,
+	"pie":     excelize.Pie,
+	"scatter": excelize.Scatter,
+}
+
+// chartValueTypes are the column types a chart can plot.
+var chartValueTypes = map[string]bool{
+	"INTEGER":    true,
+	"FLOAT":      true,
+	"PERCENTAGE": true,
+	"CURRENCY":   true,
+	"DURATION":   true,
+	"FORMULA":    true,
+}
+
+// chartSpacing is the number of rows between stacked charts, enough for
+// the default chart height of 290 pixels.
+const chartSpacing = 16
+
+// validateCharts rejects chart specs that cannot be drawn from the columns
+// of meta.
+func validateCharts(meta types.MetaData) error {
+	columns := make(map[string]types.ColumnMeta, len(meta.Columns))
+	for _, col := range meta.Columns {
+		columns[col.Name] = col
+	}
+
+	for _, chart := range meta.Charts {
+		chartType := strings.ToLower(chart.Type)
+		if _, ok := chartTypes[chartType]; !ok {
+			return fmt.Errorf("%w: unknown chart type %q", types.ErrInvalidMeta, chart.Type)
+		}
+		if len(chart.Series) == 0 {
+			return fmt.Errorf("%w: %s chart has no series", types.ErrInvalidMeta, chartType)
+		}
+		if chartType == "pie" && len(chart.Series) > 1 {
+			return fmt.Errorf("%w: pie chart takes a single series", types.ErrInvalidMeta)
+		}
+		if _, ok := columns[chart.Category]; chart.Category != "" && !ok {
+			return fmt.Errorf("%w: chart category %q is not a column", types.ErrInvalidMeta, chart.Category)
+		}
+		for _, name := range chart.Series {
+			col, ok := columns[name]
+			if !ok {
+				return fmt.Errorf("%w: chart series %q is not a column", types.ErrInvalidMeta, name)
+			}
+			if !chartValueTypes[col.Type] {
+				return fmt.Errorf("%w: chart series %q is a %s column, not a number", types.ErrInvalidMeta, name, col.Type)
+			}
+		}
+		if chart.Anchor != "" {
+			if _, _, err := excelize.CellNameToCoordinates(chart.Anchor); err != nil {
+				return fmt.Errorf("%w: invalid chart anchor %q", types.ErrInvalidMeta, chart.Anchor)
+			}
+		}
+	}
+	return nil
+}
+
+// setCharts draws the charts of a sheet whose data ends at lastRow. Sheets
+// named by the charts are added when they do not exist.
+func setCharts(f *excelize.File, sheetName string, meta types.MetaData, lastRow int, wb *workbook) error {
+	for _, chart := range meta.Charts {
+		target := chart.Sheet
+		if target == "" {
+			target = sheetName
+		}
+		if index, _ := f.GetSheetIndex(target); index == -1 {
+			if _, err := f.NewSheet(target); err != nil {
+				return err
+			}
+		}
+
+		anchor := chart.Anchor
+		if anchor == "" {
+			colName := "B"
+			if strings.EqualFold(target, sheetName) {
+				colName = colIndexToName(len(meta.Columns) + 1)
+			}
+			key := strings.ToLower(target)
+			row := max(wb.chartRows[key], 2)
+			wb.chartRows[key] = row + chartSpacing
+			anchor = colName + strconv.Itoa(row)
+		}
+
+		if err := f.AddChart(target, anchor, newChart(chart, sheetName, meta.Columns, lastRow)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newChart returns the chart plotting the data rows of sheetName up to
+// lastRow. Series are named after the column headers.
+func newChart(spec types.Chart, sheetName string, meta []types.ColumnMeta, lastRow int) *excelize.Chart {
+	lastRow = max(lastRow, 2)
+	columnRange := func(name string) (string, string) {
+		for colIndex, col := range meta {
+			if col.Name == name {
+				colName := colIndexToName(colIndex)
+				header := fmt.Sprintf("%s!$%s$1", sheetRef(sheetName), colName)
+				values := fmt.Sprintf("%s!$%s$2:$%s$%d", sheetRef(sheetName), colName, colName, lastRow)
+				return header, values
+			}
+		}
+		return "", ""
+	}
+
+	var categories string
+	if spec.Category != "" {
+		_, categories = columnRange(spec.Category)
+	}
+
+	chart := &excelize.Chart{Type: chartTypes[strings.ToLower(spec.Type)]}
+	for _, name := range spec.Series {
+		header, values := columnRange(name)
+		chart.Series = append(chart.Series, excelize.ChartSeries{
+			Name:       header,
+			Categories: categories,
+			Values:     values,
+		})
+	}
+	if spec.Title != "" {
+		chart.Title = []excelize.RichTextRun{{Text: spec.Title}}
+	}
+	return chart
+}
@@ -1,6 +1,6 @@
 package converter
 
-import "github.com/jagac/excelify/internal/types"
+import "strings"
 
 func colIndexToName(index int) string {
 	var columnName string
@@ -11,11 +11,7 @@ func colIndexToName(index int) string {
 	return columnName
 }
 
-func getColumnIndex(meta []types.ColumnMeta, columnName string) int {
-	for i, col := range meta {
-		if col.Name == columnName {
-			return i
-		}
-	}
-	return -1
+// sheetRef quotes a sheet name for use in cell references.
+func sheetRef(sheetName string) string {
+	return "'" + strings.ReplaceAll(sheetName, "'", "''") + "'"
 }
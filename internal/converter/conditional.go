@@ -0,0 +1,190 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jagac/excelify/internal/types"
+	"github.com/xuri/excelize/v2"
+)
+
+// thresholdCriteria maps the criteria of threshold rules to excelize's.
+var thresholdCriteria = map[string]string{
+	">":           ">",
+	">=":          ">=",
+	"<":           "<",
+	"<=":          "<=",
+	"=":           "==",
+	"!=":          "!=",
+	"between":     "between",
+	"not_between": "not between",
+}
+
+var iconStyles = map[string]bool{
+	"3Arrows": true, "3ArrowsGray": true, "3Flags": true, "3Signs": true,
+	"3Symbols": true, "3Symbols2": true, "3TrafficLights1": true, "3TrafficLights2": true,
+	"4Arrows": true, "4ArrowsGray": true, "4Rating": true, "4RedToBlack": true, "4TrafficLights": true,
+	"5Arrows": true, "5ArrowsGray": true, "5Quarters": true, "5Rating": true,
+}
+
+var hexColor = regexp.MustCompile(`^#?[0-9A-Fa-f]{6}$`)
+
+// validateConditionalFormats rejects conditional formatting rules that
+// cannot be written.
+func validateConditionalFormats(col types.ColumnMeta) error {
+	for i, rule := range col.ConditionalFormats {
+		invalid := func(reason string, args ...interface{}) error {
+			return fmt.Errorf("%w: column %q: conditional format %d: %s", types.ErrInvalidMeta, col.Name, i, fmt.Sprintf(reason, args...))
+		}
+
+		switch rule.Type {
+		case "threshold":
+			switch criteria, ok := thresholdCriteria[rule.Criteria]; {
+			case !ok:
+				return invalid("unknown criteria %q", rule.Criteria)
+			case strings.HasSuffix(criteria, "between") && (rule.Min == nil || rule.Max == nil):
+				return invalid("%s needs min and max", rule.Criteria)
+			case !strings.HasSuffix(criteria, "between") && rule.Value == nil:
+				return invalid("%s needs a value", rule.Criteria)
+			}
+		case "top", "bottom":
+			if rule.Value != nil && (*rule.Value < 1 || *rule.Value != float64(int(*rule.Value))) {
+				return invalid("%s needs a whole number value of at least 1", rule.Type)
+			}
+		case "color_scale":
+			if len(rule.Colors) != 0 && len(rule.Colors) != 2 && len(rule.Colors) != 3 {
+				return invalid("color_scale needs 2 or 3 colors")
+			}
+		case "data_bar":
+			if len(rule.Colors) > 1 {
+				return invalid("data_bar takes a single color")
+			}
+		case "icon_set":
+			if rule.IconStyle != "" && !iconStyles[rule.IconStyle] {
+				return invalid("unknown icon style %q", rule.IconStyle)
+			}
+		case "duplicates", "unique":
+		default:
+			return invalid("unknown type %q", rule.Type)
+		}
+
+		for _, color := range append([]string{rule.FontColor, rule.FillColor}, rule.Colors...) {
+			if color != "" && !hexColor.MatchString(color) {
+				return invalid("invalid color %q", color)
+			}
+		}
+	}
+	return nil
+}
+
+// setConditionalFormats applies the conditional formats of every column to
+// its data cells, rows 2 to lastRow.
+func setConditionalFormats(f *excelize.File, sheetName string, meta []types.ColumnMeta, lastRow int) error {
+	if lastRow < 2 {
+		return nil
+	}
+
+	for colIndex, col := range meta {
+		if len(col.ConditionalFormats) == 0 {
+			continue
+		}
+
+		colName := colIndexToName(colIndex)
+		rangeRef := colName + "2:" + colName + strconv.Itoa(lastRow)
+		options := make([]excelize.ConditionalFormatOptions, len(col.ConditionalFormats))
+		for i, rule := range col.ConditionalFormats {
+			var err error
+			if options[i], err = conditionalFormatOptions(f, rule); err != nil {
+				return err
+			}
+		}
+		if err := f.SetConditionalFormat(sheetName, rangeRef, options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func conditionalFormatOptions(f *excelize.File, rule types.ConditionalFormat) (excelize.ConditionalFormatOptions, error) {
+	options := excelize.ConditionalFormatOptions{Criteria: "="}
+	switch rule.Type {
+	case "threshold":
+		options.Type = "cell"
+		options.Criteria = thresholdCriteria[rule.Criteria]
+		if rule.Value != nil {
+			options.Value = formatNumber(*rule.Value)
+		} else {
+			options.MinValue = formatNumber(*rule.Min)
+			options.MaxValue = formatNumber(*rule.Max)
+		}
+	case "duplicates":
+		options.Type = "duplicate"
+	case "unique":
+		options.Type = "unique"
+	case "top", "bottom":
+		options.Type = rule.Type
+		options.Value = "10"
+		if rule.Value != nil {
+			options.Value = formatNumber(*rule.Value)
+		}
+		options.Percent = rule.Percent
+	case "data_bar":
+		options.Type = "data_bar"
+		options.MinType, options.MaxType = "min", "max"
+		options.BarColor = color(rule.Colors, 0, "638EC6")
+	case "color_scale":
+		options.MinType, options.MaxType = "min", "max"
+		options.MinColor = color(rule.Colors, 0, "F8696B")
+		if len(rule.Colors) == 2 {
+			options.Type = "2_color_scale"
+			options.MaxColor = color(rule.Colors, 1, "")
+			break
+		}
+		options.Type = "3_color_scale"
+		options.MidType, options.MidValue = "percentile", "50"
+		options.MidColor = color(rule.Colors, 1, "FFEB84")
+		options.MaxColor = color(rule.Colors, 2, "63BE7B")
+	case "icon_set":
+		options.Type = "icon_set"
+		options.IconStyle = rule.IconStyle
+		if options.IconStyle == "" {
+			options.IconStyle = "3Arrows"
+		}
+		options.ReverseIcons = rule.ReverseIcons
+	}
+
+	switch options.Type {
+	case "cell", "duplicate", "unique", "top", "bottom":
+		fontColor, fillColor := rule.FontColor, rule.FillColor
+		if fontColor == "" && fillColor == "" {
+			fontColor, fillColor = "9C0006", "FFC7CE"
+		}
+		style := &excelize.Style{}
+		if fontColor != "" {
+			style.Font = &excelize.Font{Color: "#" + strings.TrimPrefix(fontColor, "#")}
+		}
+		if fillColor != "" {
+			style.Fill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"#" + strings.TrimPrefix(fillColor, "#")}}
+		}
+		format, err := f.NewConditionalStyle(style)
+		if err != nil {
+			return options, err
+		}
+		options.Format = format
+	}
+	return options, nil
+}
+
+// color returns colors[i] as #RRGGBB, or fallback when it is missing.
+func color(colors []string, i int, fallback string) string {
+	if i < len(colors) {
+		fallback = colors[i]
+	}
+	return "#" + strings.TrimPrefix(fallback, "#")
+}
+
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -2,8 +2,13 @@ package converter
 
 import (
 	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/jagac/excelify/internal/types"
 	"github.com/xuri/excelize/v2"
@@ -15,87 +20,314 @@ func NewConverter() types.Converter {
 	return &ConverterImpl{}
 }
 
-func (c *ConverterImpl) ConvertToExcel(jsonData []map[string]interface{}, meta []types.ColumnMeta) (*bytes.Buffer, error) {
+// sheetWriterFunc writes the headers and rows of a single sheet into f.
+type sheetWriterFunc func(f *excelize.File, sheetName string, sheet types.Sheet, wb *workbook) error
 
-	f := excelize.NewFile()
-	sheetName := "Sheet1"
+// workbook holds what the sheets of a workbook share while they are written.
+type workbook struct {
+	styles *ExcelStyles
+	report *errorReport
+	lists  *listSheet
+	// chartRows holds the row of the next chart placed without an anchor,
+	// by lowercased sheet name.
+	chartRows map[string]int
+}
 
-	if _, err := f.NewSheet(sheetName); err != nil {
+func (c *ConverterImpl) ConvertToExcel(sheets []types.Sheet, opts types.ExcelOptions) (*bytes.Buffer, error) {
+	f, err := buildWorkbook(sheets, opts, writeSheet)
+	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 
-	if err := f.SetPanes(sheetName, &excelize.Panes{
-		Freeze:      true,
-		YSplit:      1,
-		TopLeftCell: "A2",
-		ActivePane:  "bottomLeft",
-	}); err != nil {
+	var buffer bytes.Buffer
+	if err := f.Write(&buffer); err != nil {
 		return nil, err
 	}
 
+	return &buffer, nil
+}
+
+// buildWorkbook closes the workbook when it fails, so that the temporary
+// files of stream writers that spilled to disk are removed.
+func buildWorkbook(sheets []types.Sheet, opts types.ExcelOptions, writeSheet sheetWriterFunc) (_ *excelize.File, err error) {
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("no sheets provided")
+	}
+
+	f := excelize.NewFile()
+	defer func() {
+		if err != nil {
+			f.Close()
+		}
+	}()
+
 	styles, err := createStyles(f)
 	if err != nil {
 		return nil, err
 	}
 
-	headers := createHeaders(meta)
-	if err := setHeaders(f, sheetName, headers, styles); err != nil {
+	sheets, sheetNames, err := prepareSheets(sheets, opts)
+	if err != nil {
 		return nil, err
 	}
 
-	if err := setData(f, sheetName, jsonData, meta, styles); err != nil {
-		return nil, err
+	report := &errorReport{opts: opts}
+
+	for _, sheet := range sheets {
+		if err := styles.registerColumns(f, sheet.Meta.Columns); err != nil {
+			return nil, err
+		}
 	}
 
-	if err := adjustColumnWidths(f, sheetName, jsonData, meta); err != nil {
+	if err := resolveTables(sheets); err != nil {
 		return nil, err
 	}
-
-	lastColIndex := len(meta) - 1
-	lastColName := colIndexToName(lastColIndex)
-	rangeString := "A1:" + lastColName + "1"
-	if err := f.AutoFilter(sheetName, rangeString, []excelize.AutoFilterOptions{}); err != nil {
+	if err := validateTargetSheets(sheets, sheetNames); err != nil {
 		return nil, err
 	}
 
-	if err := setColumnVisibility(f, sheetName, meta, styles); err != nil {
-		return nil, err
+	// All sheets exist before any is written, so that sheets added while
+	// writing can pick names that are not taken.
+	for i, sheetName := range sheetNames {
+		if i == 0 {
+			if err := f.SetSheetName(f.GetSheetName(0), sheetName); err != nil {
+				return nil, err
+			}
+		} else if _, err := f.NewSheet(sheetName); err != nil {
+			return nil, err
+		}
 	}
 
-	if err := f.SetDefaultFont("Aptos Narrow"); err != nil {
-		return nil, err
+	wb := &workbook{styles: styles, report: report, lists: &listSheet{}, chartRows: make(map[string]int)}
+	for i, sheet := range sheets {
+		if err := writeSheet(f, sheetNames[i], sheet, wb); err != nil {
+			return nil, fmt.Errorf("sheet %q: %w", sheetNames[i], err)
+		}
 	}
 
-	var buffer bytes.Buffer
-	if err := f.Write(&buffer); err != nil {
+	if report.total > 0 {
+		report.sort(sheetNames)
+		if opts.Mode != types.ModeLenient {
+			return nil, report.conversionError()
+		}
+		if err := report.writeSheet(f, styles); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := f.SetDefaultFont("Aptos Narrow"); err != nil {
 		return nil, err
 	}
 
-	return &buffer, nil
+	return f, nil
 }
 
-func (c *ConverterImpl) ConvertToJson(f *excelize.File) ([]byte, error) {
-	sheetName := f.GetSheetName(0)
-	rows, err := f.GetRows(sheetName)
+// prepareSheets names the sheets, explodes their rows, infers missing
+// column meta and validates it, returning the sheets to write and their
+// names.
+func prepareSheets(sheets []types.Sheet, opts types.ExcelOptions) ([]types.Sheet, []string, error) {
+	sheetNames, err := resolveSheetNames(sheets)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get rows: %w", err)
+		return nil, nil, err
+	}
+
+	sheets = slices.Clone(sheets)
+	for i, sheet := range sheets {
+		if sheet, err = explodeSheet(sheet, opts.Explode); err != nil {
+			return nil, nil, err
+		}
+		if sheets[i], err = inferColumns(sheet); err != nil {
+			return nil, nil, fmt.Errorf("sheet %q: %w", sheetNames[i], err)
+		}
+	}
+
+	for i, sheet := range sheets {
+		if err := validateMeta(sheet.Meta); err != nil {
+			return nil, nil, fmt.Errorf("sheet %q: %w", sheetNames[i], err)
+		}
+	}
+	return sheets, sheetNames, nil
+}
+
+// validateMeta rejects sheet meta the converter cannot write.
+func validateMeta(meta types.MetaData) error {
+	if err := validateColumns(meta.Columns); err != nil {
+		return err
+	}
+	if err := validateTable(meta); err != nil {
+		return err
+	}
+	if err := validateCharts(meta); err != nil {
+		return err
+	}
+	if err := validatePivot(meta); err != nil {
+		return err
+	}
+	if meta.EntryRows < 0 || meta.EntryRows > maxEntryRows {
+		return fmt.Errorf("%w: entry_rows must be between 0 and %d", types.ErrInvalidMeta, maxEntryRows)
+	}
+	return nil
+}
+
+func writeSheet(f *excelize.File, sheetName string, sheet types.Sheet, wb *workbook) error {
+	styles := wb.styles
+	meta := sheet.Meta.Columns
+	jsonData := sheet.Data
+	if sheet.Rows != nil {
+		var err error
+		if jsonData, err = readRows(sheet.Rows, -1); err != nil {
+			return err
+		}
+	}
+
+	if err := f.SetPanes(sheetName, &excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	}); err != nil {
+		return err
 	}
 
+	headers := createHeaders(meta)
+	if err := setHeaders(f, sheetName, headers, styles); err != nil {
+		return err
+	}
+
+	if err := setData(f, sheetName, jsonData, meta, styles, wb.report); err != nil {
+		return err
+	}
+
+	if err := setEntryRows(f, sheetName, meta, len(jsonData)+2, sheet.Meta.EntryRows, styles); err != nil {
+		return err
+	}
+
+	if err := adjustColumnWidths(f, sheetName, jsonData, meta); err != nil {
+		return err
+	}
+
+	lastRow := len(jsonData) + 1 + sheet.Meta.EntryRows
+	switch {
+	case len(meta) == 0:
+	case sheet.Meta.Table != nil:
+		if err := f.AddTable(sheetName, newTable(sheet.Meta.Table, len(meta), lastRow)); err != nil {
+			return err
+		}
+	default:
+		lastColIndex := len(meta) - 1
+		lastColName := colIndexToName(lastColIndex)
+		rangeString := "A1:" + lastColName + "1"
+		if err := f.AutoFilter(sheetName, rangeString, []excelize.AutoFilterOptions{}); err != nil {
+			return err
+		}
+	}
+
+	if err := setConditionalFormats(f, sheetName, meta, lastRow); err != nil {
+		return err
+	}
+	if err := setDataValidations(f, sheetName, meta, lastRow, wb.lists); err != nil {
+		return err
+	}
+	if err := setCharts(f, sheetName, sheet.Meta, lastRow, wb); err != nil {
+		return err
+	}
+	if err := setPivot(f, sheetName, sheet.Meta, lastRow); err != nil {
+		return err
+	}
+
+	totalsRowNum := totalsRowNumber(lastRow, sheet.Meta.Table != nil)
+	if err := setTotals(f, sheetName, totalsRow(meta, lastRow, styles), totalsRowNum); err != nil {
+		return err
+	}
+
+	return setColumnVisibility(f, sheetName, meta, styles)
+}
+
+// readRows reads up to limit rows from rows, or all of them if limit is
+// negative.
+func readRows(rows types.RowReader, limit int) ([]map[string]interface{}, error) {
 	var result []map[string]interface{}
+	for limit < 0 || len(result) < limit {
+		row, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, nil
+}
 
-	headers := rows[0]
-	for _, row := range rows[1:] {
-		rowData := make(map[string]interface{})
-		for i, cell := range row {
-			rowData[headers[i]] = cell
+// resolveSheetNames fills in default names for unnamed sheets and rejects
+// duplicates, since Excel compares sheet names case-insensitively.
+func resolveSheetNames(sheets []types.Sheet) ([]string, error) {
+	names := make([]string, len(sheets))
+	seen := make(map[string]bool, len(sheets))
+	for i, sheet := range sheets {
+		name := sheet.Name
+		if name == "" {
+			name = "Sheet" + strconv.Itoa(i+1)
+		}
+		key := strings.ToLower(name)
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate sheet name %q", name)
 		}
-		result = append(result, rowData)
+		seen[key] = true
+		names[i] = name
 	}
+	return names, nil
+}
 
-	jsonData, err := json.Marshal(result)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+// validateTargetSheets rejects charts placed on another sheet of data, and
+// pivot tables placed on any, since that sheet may already be written when
+// they are added. The sheets they name must be valid sheet names, and pivot
+// tables cannot refer to sheets with "!" in their name, which excelize reads
+// as the end of the sheet name in a range.
+func validateTargetSheets(sheets []types.Sheet, sheetNames []string) error {
+	isDataSheet := func(name string) bool {
+		return slices.ContainsFunc(sheetNames, func(sheetName string) bool {
+			return strings.EqualFold(name, sheetName)
+		})
 	}
 
-	return jsonData, nil
+	for i, sheet := range sheets {
+		for _, chart := range sheet.Meta.Charts {
+			if err := checkSheetName(chart.Sheet); chart.Sheet != "" && err != nil {
+				return fmt.Errorf("%w: sheet %q: chart sheet %q %v", types.ErrInvalidMeta, sheetNames[i], chart.Sheet, err)
+			}
+			if chart.Sheet != "" && !strings.EqualFold(chart.Sheet, sheetNames[i]) && isDataSheet(chart.Sheet) {
+				return fmt.Errorf("%w: sheet %q: charts can only be placed on their own sheet or a new one, not on %q", types.ErrInvalidMeta, sheetNames[i], chart.Sheet)
+			}
+		}
+		pivot := sheet.Meta.Pivot
+		if pivot == nil {
+			continue
+		}
+		if err := checkSheetName(pivot.Sheet); pivot.Sheet != "" && err != nil {
+			return fmt.Errorf("%w: sheet %q: pivot sheet %q %v", types.ErrInvalidMeta, sheetNames[i], pivot.Sheet, err)
+		}
+		if strings.Contains(sheetNames[i], "!") || strings.Contains(pivot.Sheet, "!") {
+			return fmt.Errorf("%w: sheet %q: pivot tables cannot use sheet names containing \"!\"", types.ErrInvalidMeta, sheetNames[i])
+		}
+		if isDataSheet(pivot.Sheet) {
+			return fmt.Errorf("%w: sheet %q: pivot tables can only be placed on a new sheet, not on %q", types.ErrInvalidMeta, sheetNames[i], pivot.Sheet)
+		}
+	}
+	return nil
+}
+
+// checkSheetName rejects names Excel does not allow for sheets.
+func checkSheetName(name string) error {
+	switch {
+	case utf8.RuneCountInString(name) > excelize.MaxSheetNameLength:
+		return fmt.Errorf("is longer than %d characters", excelize.MaxSheetNameLength)
+	case strings.HasPrefix(name, "'") || strings.HasSuffix(name, "'"):
+		return errors.New("starts or ends with a single quote")
+	case strings.ContainsAny(name, `:\/?*[]`):
+		return errors.New(`contains one of :\/?*[]`)
+	}
+	return nil
 }
@@ -0,0 +1,211 @@
+package converter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jagac/excelify/internal/types"
+)
+
+// csvEncoder writes a single sheet as delimited text, with the column
+// headers in the first line and the values converted like the cells of a
+// workbook.
+type csvEncoder struct {
+	format      string
+	delimiter   string
+	contentType string
+}
+
+func (e csvEncoder) ContentType() string {
+	return e.contentType
+}
+
+// Encode writes the text to a temporary file first, so that a conversion
+// error is returned before anything reaches w, as it is for workbooks.
+// Lenient mode is rejected, since text has no room for the Errors sheet that
+// lists the offending values.
+func (e csvEncoder) Encode(w io.Writer, sheets []types.Sheet, opts types.ExcelOptions) error {
+	if len(sheets) != 1 {
+		return fmt.Errorf("%w: %s output takes a single sheet", types.ErrInvalidOptions, e.format)
+	}
+	if opts.Mode == types.ModeLenient {
+		return fmt.Errorf("%w: %s output does not support lenient mode", types.ErrInvalidOptions, e.format)
+	}
+	csvOpts, err := e.resolveOptions(opts.CSV)
+	if err != nil {
+		return err
+	}
+
+	sheets, sheetNames, err := prepareSheets(sheets, opts)
+	if err != nil {
+		return err
+	}
+	sheet, sheetName := sheets[0], sheetNames[0]
+	meta := sheet.Meta.Columns
+
+	spool, err := os.CreateTemp("", "excelify-*."+e.format)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	cw := &csvWriter{w: bufio.NewWriter(spool), delimiter: csvOpts.Delimiter, quoteAll: csvOpts.Quoting == types.QuotingAll}
+	if csvOpts.BOM {
+		cw.w.WriteString("\uFEFF")
+	}
+	cw.writeRow(createHeaders(meta))
+
+	// Cells have no styles here, so the styles of converted values are
+	// discarded.
+	styles := &ExcelStyles{}
+	report := &errorReport{opts: opts}
+	fields := make([]string, len(meta))
+	rowIndex := 0
+	writeRow := func(row map[string]interface{}) {
+		for colIndex, col := range meta {
+			value, _ := lookupValue(row, col.Name)
+			value, _ = report.convert(value, col, styles, sheetName, rowIndex, colIndex)
+			fields[colIndex] = csvValue(value, col, csvOpts)
+		}
+		cw.writeRow(fields)
+		rowIndex++
+	}
+
+	if sheet.Rows == nil {
+		for _, row := range sheet.Data {
+			writeRow(row)
+		}
+	} else {
+		for {
+			row, err := sheet.Rows.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			writeRow(row)
+		}
+	}
+
+	if report.total > 0 {
+		return report.conversionError()
+	}
+	if err := cw.w.Flush(); err != nil {
+		return err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = io.Copy(w, spool)
+	return err
+}
+
+// resolveOptions fills in the defaults of opts and rejects settings the
+// encoder cannot write.
+func (e csvEncoder) resolveOptions(opts types.CSVOptions) (types.CSVOptions, error) {
+	if opts.Delimiter == "" {
+		opts.Delimiter = e.delimiter
+	}
+	if utf8.RuneCountInString(opts.Delimiter) != 1 || strings.ContainsAny(opts.Delimiter, "\"\r\n") {
+		return opts, fmt.Errorf("%w: delimiter must be a single character other than a quote or line break, got %q", types.ErrInvalidOptions, opts.Delimiter)
+	}
+
+	switch opts.Quoting {
+	case "":
+		opts.Quoting = types.QuotingMinimal
+	case types.QuotingMinimal, types.QuotingAll:
+	default:
+		return opts, fmt.Errorf("%w: unknown quoting %q", types.ErrInvalidOptions, opts.Quoting)
+	}
+
+	if opts.DateFormat == "" {
+		opts.DateFormat = "2006-01-02"
+	}
+	if opts.DatetimeFormat == "" {
+		opts.DatetimeFormat = "2006-01-02 15:04:05"
+	}
+	if opts.TimeFormat == "" {
+		opts.TimeFormat = "15:04:05"
+	}
+	return opts, nil
+}
+
+// csvValue returns the text of a value converted for col. Times of day and
+// durations, which are converted to fractions of a day for Excel, are
+// written as clock times again, and formulas are written with their "=".
+func csvValue(value interface{}, col types.ColumnMeta, opts types.CSVOptions) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case formula:
+		return "=" + string(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case time.Time:
+		if col.Type == "DATE" {
+			return v.Format(opts.DateFormat)
+		}
+		return v.Format(opts.DatetimeFormat)
+	case float64:
+		switch col.Type {
+		case "TIME":
+			seconds := time.Duration(math.Round(v*86400)) * time.Second
+			return time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC).Add(seconds).Format(opts.TimeFormat)
+		case "DURATION":
+			return formatDuration(v * 86400)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// formatDuration writes a number of seconds as h:mm:ss, with hours beyond a
+// day, like the [h]:mm:ss format of DURATION cells.
+func formatDuration(seconds float64) string {
+	sign := ""
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	total := int64(math.Round(seconds))
+	return fmt.Sprintf("%s%d:%02d:%02d", sign, total/3600, total/60%60, total%60)
+}
+
+// csvWriter writes delimited rows, quoting fields that hold the delimiter,
+// quotes or line breaks, or every field with quoteAll. Write errors are kept
+// by the bufio.Writer and returned by its Flush.
+type csvWriter struct {
+	w         *bufio.Writer
+	delimiter string
+	quoteAll  bool
+}
+
+func (cw *csvWriter) writeRow(fields []string) {
+	for i, field := range fields {
+		if i > 0 {
+			cw.w.WriteString(cw.delimiter)
+		}
+		if cw.quoteAll || strings.Contains(field, cw.delimiter) || strings.ContainsAny(field, "\"\r\n") {
+			cw.w.WriteByte('"')
+			cw.w.WriteString(strings.ReplaceAll(field, `"`, `""`))
+			cw.w.WriteByte('"')
+			continue
+		}
+		cw.w.WriteString(field)
+	}
+	cw.w.WriteByte('\n')
+}
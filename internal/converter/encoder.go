@@ -0,0 +1,34 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jagac/excelify/internal/types"
+)
+
+func (c *ConverterImpl) Encoder(format string) (types.Encoder, error) {
+	switch strings.ToLower(format) {
+	case "", types.FormatXLSX:
+		return xlsxEncoder{converter: c}, nil
+	case types.FormatCSV:
+		return csvEncoder{format: types.FormatCSV, delimiter: ",", contentType: "text/csv; charset=utf-8"}, nil
+	case types.FormatTSV:
+		return csvEncoder{format: types.FormatTSV, delimiter: "\t", contentType: "text/tab-separated-values; charset=utf-8"}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown format %q", types.ErrInvalidOptions, format)
+}
+
+// xlsxEncoder streams workbooks like StreamToExcel.
+type xlsxEncoder struct {
+	converter *ConverterImpl
+}
+
+func (e xlsxEncoder) Encode(w io.Writer, sheets []types.Sheet, opts types.ExcelOptions) error {
+	return e.converter.StreamToExcel(w, sheets, opts)
+}
+
+func (e xlsxEncoder) ContentType() string {
+	return types.ContentTypeXLSX
+}
@@ -0,0 +1,271 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jagac/excelify/internal/types"
+)
+
+// pathSegment is one step of a column path: a key into an object, or an
+// index into an array when key is empty.
+type pathSegment struct {
+	key   string
+	index int
+}
+
+// maxCachedPaths bounds the number of parsed paths kept, since paths come
+// from requests and the cache outlives them.
+const maxCachedPaths = 4096
+
+var (
+	paths       sync.Map
+	cachedPaths atomic.Int64
+)
+
+// parsePath splits a selector such as "$.order.lines[0].sku" into its
+// segments. Parsed paths are cached, since they are looked up for every cell,
+// until the cache holds maxCachedPaths of them.
+func parsePath(path string) ([]pathSegment, error) {
+	if segments, ok := paths.Load(path); ok {
+		return segments.([]pathSegment), nil
+	}
+
+	var segments []pathSegment
+	for _, part := range strings.Split(strings.TrimPrefix(path, "$."), ".") {
+		key, rest, hasIndex := strings.Cut(part, "[")
+		if key == "" {
+			return nil, fmt.Errorf("invalid path %q", path)
+		}
+		segments = append(segments, pathSegment{key: key})
+
+		for hasIndex {
+			number, after, ok := strings.Cut(rest, "]")
+			index, err := strconv.Atoi(number)
+			if !ok || err != nil || index < 0 || (after != "" && after[0] != '[') {
+				return nil, fmt.Errorf("invalid path %q", path)
+			}
+			segments = append(segments, pathSegment{index: index})
+			rest, hasIndex = strings.CutPrefix(after, "[")
+		}
+	}
+
+	if cachedPaths.Load() < maxCachedPaths {
+		if _, loaded := paths.LoadOrStore(path, segments); !loaded {
+			cachedPaths.Add(1)
+		}
+	}
+	return segments, nil
+}
+
+// lookupValue returns the value of a column in row. A key that exists as is
+// wins over reading the name as a path into nested objects and arrays.
+func lookupValue(row map[string]interface{}, name string) (interface{}, bool) {
+	if value, ok := row[name]; ok || !strings.ContainsAny(name, ".[") {
+		return value, ok
+	}
+
+	segments, err := parsePath(name)
+	if err != nil {
+		return nil, false
+	}
+	return lookupSegments(row, segments)
+}
+
+func lookupSegments(row map[string]interface{}, segments []pathSegment) (interface{}, bool) {
+	var value interface{} = row
+	for _, segment := range segments {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if value, ok = v[segment.key]; segment.key == "" || !ok {
+				return nil, false
+			}
+		case []interface{}:
+			if segment.key != "" || segment.index >= len(v) {
+				return nil, false
+			}
+			value = v[segment.index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// flattenValue turns the nested values of a cell into text: arrays are
+// joined with the delimiter and objects are written as JSON.
+func flattenValue(value interface{}, delimiter string) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		if delimiter == "" {
+			delimiter = ", "
+		}
+		parts := make([]string, len(v))
+		for i, element := range v {
+			parts[i] = elementText(element)
+		}
+		return strings.Join(parts, delimiter)
+	case map[string]interface{}:
+		return elementText(v)
+	}
+	return value
+}
+
+func elementText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+// explodeSheet replaces every row of the sheet with one row per element of
+// the array at path. Rows where the array is missing or empty are kept once.
+func explodeSheet(sheet types.Sheet, path string) (types.Sheet, error) {
+	if path == "" {
+		return sheet, nil
+	}
+	segments, err := parsePath(path)
+	if err != nil {
+		return sheet, fmt.Errorf("%w: explode: %v", types.ErrInvalidOptions, err)
+	}
+
+	if sheet.Rows != nil {
+		sheet.Rows = &explodeRows{rows: sheet.Rows, segments: segments}
+		return sheet, nil
+	}
+
+	var data []map[string]interface{}
+	for _, row := range sheet.Data {
+		data = append(data, explodeRow(row, segments)...)
+	}
+	sheet.Data = data
+	return sheet, nil
+}
+
+func explodeRow(row map[string]interface{}, segments []pathSegment) []map[string]interface{} {
+	value, _ := lookupSegments(row, segments)
+	array, _ := value.([]interface{})
+	if len(array) == 0 {
+		return []map[string]interface{}{row}
+	}
+
+	rows := make([]map[string]interface{}, len(array))
+	for i, element := range array {
+		rows[i] = replaceSegments(row, segments, element).(map[string]interface{})
+	}
+	return rows
+}
+
+// replaceSegments returns a copy of value with the element at segments set
+// to replacement. Only the objects and arrays along the path are copied.
+func replaceSegments(value interface{}, segments []pathSegment, replacement interface{}) interface{} {
+	if len(segments) == 0 {
+		return replacement
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			copied[key] = child
+		}
+		copied[segments[0].key] = replaceSegments(v[segments[0].key], segments[1:], replacement)
+		return copied
+	case []interface{}:
+		copied := append([]interface{}(nil), v...)
+		copied[segments[0].index] = replaceSegments(v[segments[0].index], segments[1:], replacement)
+		return copied
+	}
+	return value
+}
+
+// explodeRows explodes streamed rows as they are read.
+type explodeRows struct {
+	rows     types.RowReader
+	segments []pathSegment
+	pending  []map[string]interface{}
+}
+
+func (r *explodeRows) Next() (map[string]interface{}, error) {
+	for len(r.pending) == 0 {
+		row, err := r.rows.Next()
+		if err != nil {
+			return nil, err
+		}
+		r.pending = explodeRow(row, r.segments)
+	}
+
+	row := r.pending[0]
+	r.pending = r.pending[1:]
+	return row, nil
+}
+
+// Keys forwards the key order of the underlying rows.
+func (r *explodeRows) Keys() []string {
+	if order, ok := r.rows.(types.KeyOrder); ok {
+		return order.Keys()
+	}
+	return nil
+}
+
+// flattenKeys returns the columns of rows, with nested objects expanded to
+// dot paths such as "address.city". Top-level keys follow order where it is
+// known. Other keys are added alphabetically, since maps do not keep the
+// order they were decoded in.
+func flattenKeys(rows []map[string]interface{}, order []string) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	var add func(prefix string, object map[string]interface{}, order []string)
+	add = func(prefix string, object map[string]interface{}, order []string) {
+		ordered := make(map[string]bool, len(order))
+		for _, key := range order {
+			ordered[key] = true
+		}
+		var rest []string
+		for key := range object {
+			if !ordered[key] {
+				rest = append(rest, key)
+			}
+		}
+		sort.Strings(rest)
+
+		for _, key := range slices.Concat(order, rest) {
+			value, ok := object[key]
+			if !ok {
+				continue
+			}
+			path := prefix + key
+			if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+				add(path+".", nested, nil)
+				continue
+			}
+			if !seen[path] {
+				seen[path] = true
+				keys = append(keys, path)
+			}
+		}
+	}
+
+	for _, row := range rows {
+		add("", row, order)
+	}
+	return keys
+}
@@ -8,13 +8,20 @@ import (
 func createHeaders(meta []types.ColumnMeta) []string {
 	var headers []string
 	for _, col := range meta {
-		headers = append(headers, col.Name)
+		header := col.Name
+		if col.Label != "" {
+			header = col.Label
+		}
+		headers = append(headers, header)
 	}
 
 	return headers
 }
 
 func setHeaders(f *excelize.File, sheetName string, headers []string, style *ExcelStyles) error {
+	if len(headers) == 0 {
+		return nil
+	}
 	for i, header := range headers {
 		cell := colIndexToName(i) + "1"
 		if err := f.SetCellStr(sheetName, cell, header); err != nil {
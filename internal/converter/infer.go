@@ -0,0 +1,165 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jagac/excelify/internal/types"
+)
+
+// inferSampleRows is the number of streamed rows read to infer columns when
+// a sheet has no column meta.
+const inferSampleRows = 1000
+
+// inferColumns fills in the column meta of a sheet that has none, taking the
+// columns from the keys of its rows, nested objects included, and guessing
+// each column's type from its values. Streamed rows are sampled and then
+// replayed, and a later row with a key the sample did not have fails the
+// conversion rather than losing the value.
+func inferColumns(sheet types.Sheet) (types.Sheet, error) {
+	if len(sheet.Meta.Columns) > 0 {
+		return sheet, nil
+	}
+
+	rows := sheet.Data
+	keys := sheet.Keys
+	if sheet.Rows != nil {
+		sample, err := readRows(sheet.Rows, inferSampleRows)
+		if err != nil {
+			return sheet, err
+		}
+		rows = sample
+		keys = nil
+		if order, ok := sheet.Rows.(types.KeyOrder); ok {
+			keys = order.Keys()
+		}
+	}
+	keys = flattenKeys(rows, keys)
+	if sheet.Rows != nil {
+		known := make(map[string]bool, len(keys))
+		for _, key := range keys {
+			known[key] = true
+		}
+		rest := &inferredRows{next: sheet.Rows, known: known, index: len(rows)}
+		sheet.Rows = &replayRows{rows: rows, next: rest}
+	}
+
+	columns := make([]types.ColumnMeta, len(keys))
+	for i, key := range keys {
+		columns[i] = types.ColumnMeta{Name: key, Type: inferType(rows, key)}
+	}
+	sheet.Meta.Columns = columns
+	return sheet, nil
+}
+
+// inferType picks the narrowest type that fits every non-empty value of the
+// column, falling back to STRING for mixed or unrecognised values.
+func inferType(rows []map[string]interface{}, key string) string {
+	inferred := ""
+	for _, row := range rows {
+		var valueType string
+		value, _ := lookupValue(row, key)
+		switch v := value.(type) {
+		case nil:
+			continue
+		case bool:
+			valueType = "BOOLEAN"
+		case float64:
+			valueType = "INTEGER"
+			if v != math.Trunc(v) || math.Abs(v) > 1<<53 {
+				valueType = "FLOAT"
+			}
+		case string:
+			if v == "" {
+				continue
+			}
+			valueType = stringType(v)
+		default:
+			return "STRING"
+		}
+
+		switch {
+		case inferred == "" || inferred == valueType:
+			inferred = valueType
+		case isNumberType(inferred) && isNumberType(valueType):
+			inferred = "FLOAT"
+		case isDateType(inferred) && isDateType(valueType):
+			inferred = "DATETIME"
+		default:
+			return "STRING"
+		}
+	}
+
+	if inferred == "" {
+		return "STRING"
+	}
+	return inferred
+}
+
+// isoDatetimeLayouts are the string layouts recognised as DATETIME values.
+var isoDatetimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+func stringType(value string) string {
+	if _, err := time.Parse("2006-01-02", value); err == nil {
+		return "DATE"
+	}
+	for _, layout := range isoDatetimeLayouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return "DATETIME"
+		}
+	}
+	return "STRING"
+}
+
+func isNumberType(columnType string) bool {
+	return columnType == "INTEGER" || columnType == "FLOAT"
+}
+
+func isDateType(columnType string) bool {
+	return columnType == "DATE" || columnType == "DATETIME"
+}
+
+// inferredRows reads the streamed rows that follow the sample columns were
+// inferred from, rejecting rows with keys outside of those columns.
+type inferredRows struct {
+	next  types.RowReader
+	known map[string]bool
+	index int
+}
+
+func (r *inferredRows) Next() (map[string]interface{}, error) {
+	row, err := r.next.Next()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range flattenKeys([]map[string]interface{}{row}, nil) {
+		if !r.known[key] {
+			return nil, fmt.Errorf("%w: data row %d has key %q, which is not in the first %d rows the columns were inferred from; pass meta to include it", types.ErrInvalidMeta, r.index, key, inferSampleRows)
+		}
+	}
+	r.index++
+	return row, nil
+}
+
+// replayRows returns buffered rows before continuing with the rest of a
+// RowReader.
+type replayRows struct {
+	rows []map[string]interface{}
+	next types.RowReader
+}
+
+func (r *replayRows) Next() (map[string]interface{}, error) {
+	if len(r.rows) > 0 {
+		row := r.rows[0]
+		r.rows = r.rows[1:]
+		return row, nil
+	}
+	return r.next.Next()
+}
@@ -0,0 +1,172 @@
+package converter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jagac/excelify/internal/types"
+	"github.com/xuri/excelize/v2"
+)
+
+// pivotFunctions maps the functions of pivot value fields to the subtotal
+// names excelize expects, and to the caption used for unlabelled fields.
+var pivotFunctions = map[string][2]string{
+	"sum":           {"sum", "Sum"},
+	"average":       {"average", "Average"},
+	"avg":           {"average", "Average"},
+	"count":         {"count", "Count"},
+	"count_numbers": {"countNums", "Count"},
+	"max":           {"max", "Max"},
+	"min":           {"min", "Min"},
+	"product":       {"product", "Product"},
+	"stddev":        {"stdDev", "StdDev"},
+	"var":           {"var", "Var"},
+}
+
+// validatePivot rejects a pivot table that cannot be built from the columns
+// of meta.
+func validatePivot(meta types.MetaData) error {
+	pivot := meta.Pivot
+	if pivot == nil {
+		return nil
+	}
+	if len(pivot.Data) == 0 {
+		return fmt.Errorf("%w: pivot table has no data fields", types.ErrInvalidMeta)
+	}
+
+	// Pivot fields are named after the headers, which Excel requires to be
+	// unique.
+	headers := make(map[string]bool, len(meta.Columns))
+	for _, header := range createHeaders(meta.Columns) {
+		key := strings.ToLower(header)
+		if strings.TrimSpace(header) == "" || headers[key] {
+			return fmt.Errorf("%w: pivot table headers must be unique and not blank, got %q", types.ErrInvalidMeta, header)
+		}
+		headers[key] = true
+	}
+
+	columns := make(map[string]bool, len(meta.Columns))
+	for _, col := range meta.Columns {
+		columns[col.Name] = true
+	}
+	used := make(map[string]bool)
+	for _, name := range slices.Concat(pivot.Rows, pivot.Columns) {
+		if !columns[name] {
+			return fmt.Errorf("%w: pivot field %q is not a column", types.ErrInvalidMeta, name)
+		}
+		if used[name] {
+			return fmt.Errorf("%w: pivot field %q is used more than once", types.ErrInvalidMeta, name)
+		}
+		used[name] = true
+	}
+
+	labels := make(map[string]bool, len(pivot.Data))
+	for _, value := range pivot.Data {
+		if !columns[value.Column] {
+			return fmt.Errorf("%w: pivot field %q is not a column", types.ErrInvalidMeta, value.Column)
+		}
+		if _, ok := pivotFunctions[strings.ToLower(value.Function)]; value.Function != "" && !ok {
+			return fmt.Errorf("%w: pivot field %q has unknown function %q", types.ErrInvalidMeta, value.Column, value.Function)
+		}
+		key := strings.ToLower(value.Label)
+		if value.Label != "" && (headers[key] || labels[key]) {
+			return fmt.Errorf("%w: pivot label %q is already taken", types.ErrInvalidMeta, value.Label)
+		}
+		labels[key] = true
+	}
+
+	if pivot.Anchor != "" {
+		if _, _, err := excelize.CellNameToCoordinates(pivot.Anchor); err != nil {
+			return fmt.Errorf("%w: invalid pivot anchor %q", types.ErrInvalidMeta, pivot.Anchor)
+		}
+	}
+	return nil
+}
+
+// setPivot adds the pivot table of a sheet whose data ends at lastRow. Entry
+// rows are left out of its range, since they would show up as a "(blank)"
+// group. The sheet it is placed on is added when it does not exist.
+func setPivot(f *excelize.File, sheetName string, meta types.MetaData, lastRow int) error {
+	pivot := meta.Pivot
+	if pivot == nil {
+		return nil
+	}
+
+	target := pivot.Sheet
+	if target == "" {
+		target = "Pivot"
+		for i := 2; ; i++ {
+			if index, _ := f.GetSheetIndex(target); index == -1 {
+				break
+			}
+			target = fmt.Sprintf("Pivot (%d)", i)
+		}
+	}
+	if index, _ := f.GetSheetIndex(target); index == -1 {
+		if _, err := f.NewSheet(target); err != nil {
+			return err
+		}
+	}
+
+	headers := createHeaders(meta.Columns)
+	header := func(name string) string {
+		for colIndex, col := range meta.Columns {
+			if col.Name == name {
+				return headers[colIndex]
+			}
+		}
+		return ""
+	}
+
+	anchor := pivot.Anchor
+	if anchor == "" {
+		anchor = "A3"
+	}
+	col, row, err := excelize.CellNameToCoordinates(anchor)
+	if err != nil {
+		return err
+	}
+	// The end of the range is only a placeholder, since Excel lays the
+	// pivot table out again when the workbook is opened.
+	end, err := excelize.CoordinatesToCellName(col+1, row+1)
+	if err != nil {
+		return err
+	}
+
+	style := pivot.Style
+	if style == "" {
+		style = "PivotStyleLight16"
+	}
+	opts := &excelize.PivotTableOptions{
+		DataRange:           fmt.Sprintf("%s!$A$1:$%s$%d", sheetName, colIndexToName(len(meta.Columns)-1), max(lastRow-meta.EntryRows, 2)),
+		PivotTableRange:     fmt.Sprintf("%s!%s:%s", target, anchor, end),
+		RowGrandTotals:      true,
+		ColGrandTotals:      true,
+		ShowDrill:           true,
+		ShowRowHeaders:      true,
+		ShowColHeaders:      true,
+		ShowLastColumn:      true,
+		PivotTableStyleName: style,
+	}
+	for _, name := range pivot.Rows {
+		opts.Rows = append(opts.Rows, excelize.PivotTableField{Data: header(name), DefaultSubtotal: true})
+	}
+	for _, name := range pivot.Columns {
+		opts.Columns = append(opts.Columns, excelize.PivotTableField{Data: header(name), DefaultSubtotal: true})
+	}
+	for _, value := range pivot.Data {
+		function := value.Function
+		if function == "" {
+			function = "sum"
+		}
+		subtotal := pivotFunctions[strings.ToLower(function)]
+		label := value.Label
+		if label == "" {
+			label = subtotal[1] + " of " + header(value.Column)
+		}
+		opts.Data = append(opts.Data, excelize.PivotTableField{Data: header(value.Column), Name: label, Subtotal: subtotal[0]})
+	}
+
+	return f.AddPivotTable(opts)
+}
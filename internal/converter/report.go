@@ -0,0 +1,125 @@
+package converter
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/jagac/excelify/internal/types"
+	"github.com/xuri/excelize/v2"
+)
+
+// maxReportedErrors caps the number of cell errors kept in a report. Every
+// error is still counted.
+const maxReportedErrors = 1000
+
+// errorReport collects the values that could not be converted. It is shared
+// by the goroutines writing a sheet, so access goes through its mutex.
+type errorReport struct {
+	mu     sync.Mutex
+	opts   types.ExcelOptions
+	errors []types.CellError
+	total  int
+}
+
+// convert flattens nested values and converts value like convertValue.
+// FORMULA columns get their formula for the row instead of a value. Values
+// that cannot be converted are recorded and replaced according to the
+// options: left out in strict mode, since the whole conversion fails anyway,
+// and written as text or blank in lenient mode.
+func (r *errorReport) convert(value interface{}, col types.ColumnMeta, styles *ExcelStyles, sheetName string, rowIndex, colIndex int) (interface{}, int) {
+	if col.Type == "FORMULA" {
+		_, style, _ := convertValue(nil, col, styles)
+		return rowFormula(col.Formula, rowIndex+2), style
+	}
+
+	value = flattenValue(value, r.opts.ArrayDelimiter)
+	converted, style, err := convertValue(value, col, styles)
+	if err == nil {
+		return converted, style
+	}
+
+	cellRef, _ := excelize.CoordinatesToCellName(colIndex+1, rowIndex+2)
+	r.mu.Lock()
+	r.total++
+	if len(r.errors) < maxReportedErrors {
+		r.errors = append(r.errors, types.CellError{
+			Sheet:  sheetName,
+			Row:    rowIndex,
+			Cell:   cellRef,
+			Column: col.Name,
+			Value:  value,
+			Reason: err.Error(),
+		})
+	}
+	r.mu.Unlock()
+
+	if r.opts.Mode != types.ModeLenient || r.opts.InvalidValues == types.InvalidValuesBlank || value == nil {
+		return nil, styles.TextStyle
+	}
+	return fmt.Sprint(value), styles.TextStyle
+}
+
+// sort orders the errors by sheet, row and column, since rows of large
+// sheets are converted concurrently.
+func (r *errorReport) sort(sheetNames []string) {
+	slices.SortStableFunc(r.errors, func(a, b types.CellError) int {
+		if a.Sheet != b.Sheet {
+			return slices.Index(sheetNames, a.Sheet) - slices.Index(sheetNames, b.Sheet)
+		}
+		if a.Row != b.Row {
+			return a.Row - b.Row
+		}
+		aCol, _, _ := excelize.CellNameToCoordinates(a.Cell)
+		bCol, _, _ := excelize.CellNameToCoordinates(b.Cell)
+		return aCol - bCol
+	})
+}
+
+func (r *errorReport) conversionError() *types.ConversionError {
+	return &types.ConversionError{Errors: r.errors, Total: r.total}
+}
+
+// writeSheet adds the report as an extra sheet named after the first free
+// name of "Errors", "Errors (2)" and so on.
+func (r *errorReport) writeSheet(f *excelize.File, styles *ExcelStyles) error {
+	sheetName := "Errors"
+	for i := 2; ; i++ {
+		if index, _ := f.GetSheetIndex(sheetName); index == -1 {
+			break
+		}
+		sheetName = fmt.Sprintf("Errors (%d)", i)
+	}
+	if _, err := f.NewSheet(sheetName); err != nil {
+		return err
+	}
+
+	headers := []string{"sheet", "row", "cell", "column", "value", "reason"}
+	if err := setHeaders(f, sheetName, headers, styles); err != nil {
+		return err
+	}
+
+	for i, cellErr := range r.errors {
+		values := []interface{}{cellErr.Sheet, cellErr.Row, cellErr.Cell, cellErr.Column, fmt.Sprint(cellErr.Value), cellErr.Reason}
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return err
+		}
+		if err := f.SetSheetRow(sheetName, cell, &values); err != nil {
+			return err
+		}
+	}
+
+	if r.total > len(r.errors) {
+		cell, err := excelize.CoordinatesToCellName(1, len(r.errors)+2)
+		if err != nil {
+			return err
+		}
+		note := fmt.Sprintf("%d more errors not listed", r.total-len(r.errors))
+		if err := f.SetCellStr(sheetName, cell, note); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
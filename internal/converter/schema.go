@@ -0,0 +1,295 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jagac/excelify/internal/types"
+	"github.com/xuri/excelize/v2"
+)
+
+// importSchema checks and converts the rows of a sheet against column meta
+// on import.
+type importSchema struct {
+	sheetName string
+	columns   []schemaColumn
+	known     map[string]bool
+	date1904  bool
+	report    *importReport
+}
+
+// schemaColumn is a meta column and the position of its header in the
+// sheet, or -1 when the sheet does not have it.
+type schemaColumn struct {
+	meta     types.ColumnMeta
+	header   string
+	colIndex int
+}
+
+// newImportSchema matches meta columns to headers by label or name. headers
+// start at column firstCol.
+func newImportSchema(sheetName string, meta []types.ColumnMeta, headers []string, firstCol int, date1904 bool, report *importReport) *importSchema {
+	schema := &importSchema{
+		sheetName: sheetName,
+		known:     make(map[string]bool),
+		date1904:  date1904,
+		report:    report,
+	}
+	for _, col := range meta {
+		column := schemaColumn{meta: col, colIndex: -1}
+		for i, header := range headers {
+			if header == col.Name || (col.Label != "" && header == col.Label) {
+				column.header, column.colIndex = header, firstCol+i
+				schema.known[header] = true
+				break
+			}
+		}
+		if column.colIndex < 0 && col.Required {
+			report.missing = append(report.missing, types.MissingColumn{Sheet: sheetName, Column: col.Name})
+		}
+		schema.columns = append(schema.columns, column)
+	}
+	return schema
+}
+
+// apply returns row keyed by the column names of the schema, with values
+// converted to the column types. Invalid values are reported and kept as
+// read. Headers outside the schema are kept as they are.
+func (s *importSchema) apply(row map[string]interface{}, rowIndex, excelRow int) map[string]interface{} {
+	result := make(map[string]interface{}, len(row))
+	for header, value := range row {
+		if !s.known[header] {
+			result[header] = value
+		}
+	}
+
+	for _, col := range s.columns {
+		if col.colIndex < 0 {
+			result[col.meta.Name] = nil
+			continue
+		}
+
+		raw := row[col.header]
+		value, err := checkImportValue(raw, col.meta, s.date1904)
+		if err != nil {
+			cellRef, _ := excelize.CoordinatesToCellName(col.colIndex+1, excelRow)
+			s.report.add(types.CellError{
+				Sheet:  s.sheetName,
+				Row:    rowIndex,
+				Cell:   cellRef,
+				Column: col.meta.Name,
+				Value:  raw,
+				Reason: err.Error(),
+			})
+			value = raw
+		}
+		result[col.meta.Name] = value
+	}
+	return result
+}
+
+// keys returns the keys of rows produced by apply in header order, with
+// columns missing from the sheet last.
+func (s *importSchema) keys(headers []string) []string {
+	names := make(map[string]string, len(s.columns))
+	for _, col := range s.columns {
+		if col.colIndex >= 0 {
+			names[col.header] = col.meta.Name
+		}
+	}
+
+	keys := make([]string, 0, len(headers))
+	for _, header := range headers {
+		if name, ok := names[header]; ok {
+			header = name
+		}
+		keys = append(keys, header)
+	}
+	for _, col := range s.columns {
+		if col.colIndex < 0 {
+			keys = append(keys, col.meta.Name)
+		}
+	}
+	return keys
+}
+
+// checkImportValue converts a typed cell value to the column type and checks
+// it against the column's validation.
+func checkImportValue(raw interface{}, col types.ColumnMeta, date1904 bool) (interface{}, error) {
+	if raw == nil || raw == "" {
+		if col.Required {
+			return nil, fmt.Errorf("value is required")
+		}
+		return nil, nil
+	}
+
+	value, comparable, err := importValue(raw, col, date1904)
+	if err != nil {
+		return nil, err
+	}
+
+	v := col.Validation
+	if v == nil {
+		return value, nil
+	}
+	text := elementText(value)
+	if len(v.Enum) > 0 && !slices.Contains(v.Enum, text) {
+		return nil, fmt.Errorf("value is not one of the allowed values")
+	}
+	if v.MaxLength > 0 && utf8.RuneCountInString(text) > v.MaxLength {
+		return nil, fmt.Errorf("value is longer than %d characters", v.MaxLength)
+	}
+	if v.Min != nil || v.Max != nil {
+		min, max, err := validationBounds(col)
+		if err != nil {
+			return nil, err
+		}
+		if min != nil && comparable < *min {
+			return nil, fmt.Errorf("value is less than %v", v.Min)
+		}
+		if max != nil && comparable > *max {
+			return nil, fmt.Errorf("value is greater than %v", v.Max)
+		}
+	}
+	return value, nil
+}
+
+// importValue converts a typed cell value to the JSON value of the column
+// type. comparable is the value as Excel stores it, which is what range
+// validations compare.
+func importValue(raw interface{}, col types.ColumnMeta, date1904 bool) (value interface{}, comparable float64, err error) {
+	number, isNumber := raw.(float64)
+	text, isText := raw.(string)
+
+	switch col.Type {
+	case "STRING":
+		return elementText(raw), 0, nil
+	case "INTEGER", "FLOAT", "PERCENTAGE", "CURRENCY":
+		if isText {
+			if number, err = strconv.ParseFloat(text, 64); err != nil {
+				return nil, 0, fmt.Errorf("failed to convert %v to %s", raw, strings.ToLower(col.Type))
+			}
+		} else if !isNumber {
+			return nil, 0, fmt.Errorf("failed to convert %v to %s", raw, strings.ToLower(col.Type))
+		}
+		if col.Type == "INTEGER" && number != math.Trunc(number) {
+			return nil, 0, fmt.Errorf("%v is not a whole number", raw)
+		}
+		return number, number, nil
+	case "BOOLEAN":
+		if isText {
+			value, err := toBool(text)
+			return value, 0, err
+		}
+		if isNumber {
+			return number != 0, 0, nil
+		}
+		return raw, 0, nil
+	case "DATE", "DATETIME":
+		var t time.Time
+		if isNumber {
+			if t, err = excelize.ExcelDateToTime(number, date1904); err != nil {
+				return nil, 0, fmt.Errorf("failed to convert %v to %s", raw, strings.ToLower(col.Type))
+			}
+		} else {
+			// Cells hold the wall clock time shown in the column's timezone,
+			// so strings are parsed as is and placed in that timezone after.
+			parseCol := col
+			parseCol.InputFormats = append(slices.Clone(col.InputFormats), defaultDatetimeLayouts...)
+			parseCol.Timezone = ""
+			if t, err = toTime(raw, parseCol, nil); err != nil {
+				return nil, 0, err
+			}
+		}
+
+		if col.Type == "DATE" {
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+			return t.Format("2006-01-02"), excelTime(t), nil
+		}
+		if col.Timezone == "" {
+			return t.Format("2006-01-02T15:04:05"), excelTime(t), nil
+		}
+		loc, err := loadLocation(col.Timezone)
+		if err != nil {
+			return nil, 0, err
+		}
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+		return t.Format(time.RFC3339), excelTime(t), nil
+	case "TIME":
+		if isText {
+			if number, err = toTimeOfDay(text); err != nil {
+				return nil, 0, err
+			}
+		} else if !isNumber {
+			return nil, 0, fmt.Errorf("failed to convert %v to time", raw)
+		}
+		_, fraction := math.Modf(number)
+		seconds := int(math.Round(fraction * 86400))
+		value := fmt.Sprintf("%02d:%02d:%02d", seconds/3600%24, seconds/60%60, seconds%60)
+		return value, fraction, nil
+	case "DURATION":
+		days := number
+		if seconds, ok := clockDuration(text); isText && ok {
+			days = seconds / 86400
+		} else if isText {
+			duration, err := toDuration(text)
+			if err != nil {
+				return nil, 0, err
+			}
+			days = duration.(float64)
+		} else if !isNumber {
+			return nil, 0, fmt.Errorf("failed to convert %v to duration", raw)
+		}
+		return days * 86400, days, nil
+	}
+	return raw, 0, nil
+}
+
+var clockPattern = regexp.MustCompile(`^(-?)(\d+):([0-5]\d)(?::([0-5]\d(?:\.\d+)?))?$`)
+
+// clockDuration reads a duration written as h:mm:ss or h:mm, as exported
+// DURATION cells are shown, into seconds.
+func clockDuration(text string) (float64, bool) {
+	match := clockPattern.FindStringSubmatch(text)
+	if match == nil {
+		return 0, false
+	}
+	hours, _ := strconv.ParseFloat(match[2], 64)
+	minutes, _ := strconv.ParseFloat(match[3], 64)
+	var seconds float64
+	if match[4] != "" {
+		seconds, _ = strconv.ParseFloat(match[4], 64)
+	}
+	total := hours*3600 + minutes*60 + seconds
+	if match[1] == "-" {
+		total = -total
+	}
+	return total, true
+}
+
+// importReport collects the problems found while checking imported rows.
+type importReport struct {
+	missing []types.MissingColumn
+	errors  []types.CellError
+	total   int
+}
+
+func (r *importReport) add(cellErr types.CellError) {
+	r.total++
+	if len(r.errors) < maxReportedErrors {
+		r.errors = append(r.errors, cellErr)
+	}
+}
+
+func (r *importReport) err() error {
+	if len(r.missing) == 0 && r.total == 0 {
+		return nil
+	}
+	return &types.ValidationError{MissingColumns: r.missing, Errors: r.errors, Total: r.total}
+}
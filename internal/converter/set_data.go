@@ -1,51 +1,31 @@
 package converter
 
 import (
-	"fmt"
-
 	"runtime"
 	"strconv"
 	"sync"
-	"time"
 
 	"github.com/jagac/excelify/internal/types"
 	"github.com/xuri/excelize/v2"
 )
 
-func setData(f *excelize.File, sheetName string, jsonData []map[string]interface{}, meta []types.ColumnMeta, styles *ExcelStyles) error {
+func setData(f *excelize.File, sheetName string, jsonData []map[string]interface{}, meta []types.ColumnMeta, styles *ExcelStyles, report *errorReport) error {
 	const threshold = 10000
 
 	if len(jsonData) <= threshold {
-		return setDataSequential(f, sheetName, jsonData, meta, styles)
+		return setDataSequential(f, sheetName, jsonData, meta, styles, report)
 	}
-	return setDataParallel(f, sheetName, jsonData, meta, styles)
+	return setDataParallel(f, sheetName, jsonData, meta, styles, report)
 }
 
-func setDataSequential(f *excelize.File, sheetName string, jsonData []map[string]interface{}, meta []types.ColumnMeta, styles *ExcelStyles) error {
-	metaIndex := make(map[string]int)
-	for i, col := range meta {
-		metaIndex[col.Name] = i
-	}
-
+func setDataSequential(f *excelize.File, sheetName string, jsonData []map[string]interface{}, meta []types.ColumnMeta, styles *ExcelStyles, report *errorReport) error {
 	for rowIndex, row := range jsonData {
-		orderedRow := make([]interface{}, len(meta))
-
-		for colName, value := range row {
-			if colIndex, exists := metaIndex[colName]; exists {
-				orderedRow[colIndex] = value
-			}
-		}
-
-		for colIndex, value := range orderedRow {
-			colMeta := meta[colIndex]
-
-			convertedValue, style, err := convertValue(value, colMeta.Type, styles)
-			if err != nil {
-				return err
-			}
+		for colIndex, colMeta := range meta {
+			value, _ := lookupValue(row, colMeta.Name)
+			convertedValue, style := report.convert(value, colMeta, styles, sheetName, rowIndex, colIndex)
 
 			cellRef := colIndexToName(colIndex) + strconv.Itoa(rowIndex+2)
-			if err := f.SetCellValue(sheetName, cellRef, convertedValue); err != nil {
+			if err := setCell(f, sheetName, cellRef, convertedValue); err != nil {
 				return err
 			}
 			if err := f.SetCellStyle(sheetName, cellRef, cellRef, style); err != nil {
@@ -57,14 +37,12 @@ func setDataSequential(f *excelize.File, sheetName string, jsonData []map[string
 	return nil
 }
 
-func setDataParallel(f *excelize.File, sheetName string, jsonData []map[string]interface{}, meta []types.ColumnMeta, styles *ExcelStyles) error {
+func setDataParallel(f *excelize.File, sheetName string, jsonData []map[string]interface{}, meta []types.ColumnMeta, styles *ExcelStyles, report *errorReport) error {
 	numCores := runtime.NumCPU()
 	batchSize := (len(jsonData) + numCores - 1) / numCores
 
 	cellDataChan := make(chan []types.CellData, numCores)
 	var wg sync.WaitGroup
-	var firstError error
-	var mu sync.Mutex
 
 	processBatch := func(batch []map[string]interface{}, startIndex int) {
 		defer wg.Done()
@@ -72,15 +50,8 @@ func setDataParallel(f *excelize.File, sheetName string, jsonData []map[string]i
 
 		for rowIndex, row := range batch {
 			for colIndex, col := range meta {
-				value, style, err := convertValue(row[col.Name], col.Type, styles)
-				if err != nil {
-					mu.Lock()
-					if firstError == nil {
-						firstError = err
-					}
-					mu.Unlock()
-					return
-				}
+				value, _ := lookupValue(row, col.Name)
+				value, style := report.convert(value, col, styles, sheetName, startIndex+rowIndex, colIndex)
 
 				cellData = append(cellData, types.CellData{
 					RowIndex: startIndex + rowIndex,
@@ -113,12 +84,9 @@ func setDataParallel(f *excelize.File, sheetName string, jsonData []map[string]i
 
 	// Set all cell values and styles in batches
 	for cellData := range cellDataChan {
-		if firstError != nil {
-			return firstError
-		}
 		for _, cell := range cellData {
 			cellRef := colIndexToName(cell.ColIndex) + strconv.Itoa(cell.RowIndex+2)
-			if err := f.SetCellValue(sheetName, cellRef, cell.Value); err != nil {
+			if err := setCell(f, sheetName, cellRef, cell.Value); err != nil {
 				return err
 			}
 			if err := f.SetCellStyle(sheetName, cellRef, cellRef, cell.Style); err != nil {
@@ -127,60 +95,13 @@ func setDataParallel(f *excelize.File, sheetName string, jsonData []map[string]i
 		}
 	}
 
-	return firstError
+	return nil
 }
 
-func convertValue(value interface{}, colType string, styles *ExcelStyles) (interface{}, int, error) {
-	var style int
-	var err error
-
-	switch colType {
-	case "STRING":
-		style = styles.TextStyle
-		if value == nil {
-			value = ""
-		}
-	case "INTEGER":
-		style = styles.IntStyle
-		if strValue, ok := value.(string); ok {
-			if strValue == "" {
-				value = ""
-			} else {
-				value, err = strconv.Atoi(strValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to convert %v to integer: %w", strValue, err)
-				}
-			}
-		}
-	case "FLOAT":
-		style = styles.FloatStyle
-		if strValue, ok := value.(string); ok {
-			if strValue == "" {
-				value = ""
-			} else {
-				value, err = strconv.ParseFloat(strValue, 64)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to convert %v to float: %w", strValue, err)
-				}
-			}
-		}
-	case "DATETIME":
-		style = styles.DatetimeStyle
-		if strValue, ok := value.(string); ok {
-			if strValue == "" {
-				value = ""
-			} else {
-				value, err = time.Parse("2006-01-02 15:04", strValue)
-				if err != nil {
-					return nil, 0, fmt.Errorf("failed to convert %v to datetime: %w", strValue, err)
-				}
-			}
-		}
-	case "PERCENTAGE":
-		style = styles.PercentageStyle
-	default:
-		return nil, 0, nil
+// setCell writes value to cellRef, or sets it as the cell's formula.
+func setCell(f *excelize.File, sheetName, cellRef string, value interface{}) error {
+	if formula, ok := value.(formula); ok {
+		return f.SetCellFormula(sheetName, cellRef, string(formula))
 	}
-
-	return value, style, nil
+	return f.SetCellValue(sheetName, cellRef, value)
 }
@@ -0,0 +1,187 @@
+package converter
+
+import (
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/jagac/excelify/internal/types"
+	"github.com/xuri/excelize/v2"
+)
+
+// StreamToExcel builds the workbook with excelize's StreamWriter and writes
+// it straight to w. Rows are flushed to temporary files as they are written,
+// so memory stays bounded regardless of the number of rows.
+func (c *ConverterImpl) StreamToExcel(w io.Writer, sheets []types.Sheet, opts types.ExcelOptions) error {
+	f, err := buildWorkbook(sheets, opts, streamSheet)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return f.Write(w)
+}
+
+// widthSampleRows is the number of streamed rows buffered up front to size
+// the columns, since widths must be set before the first row is written.
+const widthSampleRows = 1000
+
+func streamSheet(f *excelize.File, sheetName string, sheet types.Sheet, wb *workbook) error {
+	styles, report := wb.styles, wb.report
+	meta := sheet.Meta.Columns
+	sample := sheet.Data
+	if sheet.Rows != nil {
+		var err error
+		if sample, err = readRows(sheet.Rows, widthSampleRows); err != nil {
+			return err
+		}
+	}
+
+	// The stream writer copies the worksheet it starts from, so sheet level
+	// settings such as the autofilter have to be applied before creating it.
+	// Tables bring their own filter.
+	if len(meta) > 0 && sheet.Meta.Table == nil {
+		rangeString := "A1:" + colIndexToName(len(meta)-1) + "1"
+		if err := f.AutoFilter(sheetName, rangeString, []excelize.AutoFilterOptions{}); err != nil {
+			return err
+		}
+	}
+
+	sw, err := f.NewStreamWriter(sheetName)
+	if err != nil {
+		return err
+	}
+
+	if err := sw.SetPanes(&excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	}); err != nil {
+		return err
+	}
+
+	// The stream writer has no way to mark a column as hidden, so hidden
+	// columns are collapsed to zero width instead.
+	for colIndex, width := range computeColumnWidths(sample, meta) {
+		if isHidden(meta[colIndex]) {
+			width = 0
+		}
+		if err := sw.SetColWidth(colIndex+1, colIndex+1, width); err != nil {
+			return err
+		}
+	}
+
+	headerRow := make([]interface{}, len(meta))
+	for colIndex, header := range createHeaders(meta) {
+		style := styles.HeaderStyle
+		if isHidden(meta[colIndex]) {
+			style = styles.HiddenStyle
+		}
+		headerRow[colIndex] = excelize.Cell{StyleID: style, Value: header}
+	}
+	if err := sw.SetRow("A1", headerRow); err != nil {
+		return err
+	}
+
+	rowNum := 2
+	writeRow := func(row map[string]interface{}) error {
+		values := make([]interface{}, len(meta))
+		for colIndex, col := range meta {
+			value, _ := lookupValue(row, col.Name)
+			value, style := report.convert(value, col, styles, sheetName, rowNum-2, colIndex)
+			if isHidden(col) {
+				style = styles.HiddenStyle
+			}
+			values[colIndex] = excelize.Cell{StyleID: style, Value: value}
+			if formula, ok := value.(formula); ok {
+				values[colIndex] = excelize.Cell{StyleID: style, Formula: string(formula)}
+			}
+		}
+
+		cell, err := excelize.CoordinatesToCellName(1, rowNum)
+		if err != nil {
+			return err
+		}
+		rowNum++
+		return sw.SetRow(cell, values)
+	}
+
+	for _, row := range sample {
+		if err := writeRow(row); err != nil {
+			return err
+		}
+	}
+
+	if sheet.Rows != nil {
+		for {
+			row, err := sheet.Rows.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if err := writeRow(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	entryRow := make([]interface{}, len(meta))
+	for colIndex, col := range meta {
+		entryRow[colIndex] = excelize.Cell{StyleID: columnStyle(col, styles)}
+	}
+	for i := 0; i < sheet.Meta.EntryRows; i++ {
+		if err := sw.SetRow("A"+strconv.Itoa(rowNum), entryRow); err != nil {
+			return err
+		}
+		rowNum++
+	}
+
+	// The stream writer writes the worksheet settings on Flush, so rules that
+	// need the final data range can still be added here.
+	lastRow := rowNum - 1
+	if err := setConditionalFormats(f, sheetName, meta, lastRow); err != nil {
+		return err
+	}
+	if err := setDataValidations(f, sheetName, meta, lastRow, wb.lists); err != nil {
+		return err
+	}
+	if err := setCharts(f, sheetName, sheet.Meta, lastRow, wb); err != nil {
+		return err
+	}
+	if sheet.Meta.Pivot != nil {
+		// excelize names the pivot fields after the header cells, which the
+		// stream writer keeps to itself, so they are copied to the worksheet
+		// as well. Flush writes the streamed rows in their place.
+		headers := make([]interface{}, len(meta))
+		for colIndex, header := range createHeaders(meta) {
+			headers[colIndex] = header
+		}
+		if err := f.SetSheetRow(sheetName, "A1", &headers); err != nil {
+			return err
+		}
+		if err := setPivot(f, sheetName, sheet.Meta, lastRow); err != nil {
+			return err
+		}
+	}
+
+	if totals := totalsRow(meta, lastRow, styles); totals != nil {
+		values := make([]interface{}, len(totals))
+		for i, cell := range totals {
+			values[i] = cell
+		}
+		totalsRowNum := totalsRowNumber(lastRow, sheet.Meta.Table != nil)
+		if err := sw.SetRow("A"+strconv.Itoa(totalsRowNum), values); err != nil {
+			return err
+		}
+	}
+	if len(meta) > 0 && sheet.Meta.Table != nil {
+		if err := sw.AddTable(newTable(sheet.Meta.Table, len(meta), lastRow)); err != nil {
+			return err
+		}
+	}
+
+	return sw.Flush()
+}
@@ -1,6 +1,7 @@
 package converter
 
 import (
+	"github.com/jagac/excelify/internal/types"
 	"github.com/xuri/excelize/v2"
 )
 
@@ -12,6 +13,14 @@ type ExcelStyles struct {
 	PercentageStyle int
 	TextStyle       int
 	HiddenStyle     int
+	BooleanStyle    int
+	DateStyle       int
+	TimeStyle       int
+	DurationStyle   int
+	FormulaStyle    int
+	// FormatStyles holds one style per custom number format, keyed by the
+	// format code.
+	FormatStyles map[string]int
 }
 
 func createStyles(f *excelize.File) (*ExcelStyles, error) {
@@ -35,8 +44,8 @@ func createStyles(f *excelize.File) (*ExcelStyles, error) {
 		return nil, err
 	}
 
-	exp := "yyyy-mm-dd"
-	datetimeStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &exp})
+	datetimeExp := "yyyy-mm-dd hh:mm"
+	datetimeStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &datetimeExp})
 	if err != nil {
 		return nil, err
 	}
@@ -60,6 +69,34 @@ func createStyles(f *excelize.File) (*ExcelStyles, error) {
 		return nil, err
 	}
 
+	booleanStyle, err := f.NewStyle(&excelize.Style{NumFmt: 0})
+	if err != nil {
+		return nil, err
+	}
+
+	exp := "yyyy-mm-dd"
+	dateStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &exp})
+	if err != nil {
+		return nil, err
+	}
+
+	timeExp := "hh:mm:ss"
+	timeStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &timeExp})
+	if err != nil {
+		return nil, err
+	}
+
+	durationExp := "[h]:mm:ss"
+	durationStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &durationExp})
+	if err != nil {
+		return nil, err
+	}
+
+	formulaStyle, err := f.NewStyle(&excelize.Style{NumFmt: 0})
+	if err != nil {
+		return nil, err
+	}
+
 	return &ExcelStyles{
 		HeaderStyle:     headerStyle,
 		IntStyle:        intStyle,
@@ -68,5 +105,52 @@ func createStyles(f *excelize.File) (*ExcelStyles, error) {
 		PercentageStyle: percentageStyle,
 		TextStyle:       textStyle,
 		HiddenStyle:     hiddenFontColorStyle,
+		BooleanStyle:    booleanStyle,
+		DateStyle:       dateStyle,
+		TimeStyle:       timeStyle,
+		DurationStyle:   durationStyle,
+		FormulaStyle:    formulaStyle,
+		FormatStyles:    make(map[string]int),
 	}, nil
 }
+
+// registerColumns creates the styles needed by meta that depend on the
+// column, such as custom and currency formats. Styles are created up front
+// because rows may be converted concurrently.
+func (s *ExcelStyles) registerColumns(f *excelize.File, meta []types.ColumnMeta) error {
+	for _, col := range meta {
+		if col.Format != "" {
+			if err := s.registerFormat(f, col.Format); err != nil {
+				return err
+			}
+		}
+		if col.Type == "CURRENCY" {
+			if err := s.registerFormat(f, currencyFormat(col.Currency)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ExcelStyles) registerFormat(f *excelize.File, format string) error {
+	if _, ok := s.FormatStyles[format]; ok {
+		return nil
+	}
+
+	style, err := f.NewStyle(&excelize.Style{CustomNumFmt: &format})
+	if err != nil {
+		return err
+	}
+	s.FormatStyles[format] = style
+	return nil
+}
+
+// columnStyle returns the style of the column's data cells.
+func columnStyle(col types.ColumnMeta, styles *ExcelStyles) int {
+	if isHidden(col) {
+		return styles.HiddenStyle
+	}
+	_, style, _ := convertValue(nil, col, styles)
+	return style
+}
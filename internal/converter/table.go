@@ -0,0 +1,178 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jagac/excelify/internal/types"
+	"github.com/xuri/excelize/v2"
+)
+
+// totalFunctions maps the functions of the totals row to SUBTOTAL function
+// numbers, which leave out rows hidden by a filter.
+var totalFunctions = map[string]int{
+	"average":       101,
+	"avg":           101,
+	"count_numbers": 102,
+	"count":         103,
+	"max":           104,
+	"min":           105,
+	"stddev":        107,
+	"sum":           109,
+	"var":           110,
+}
+
+var tableName = regexp.MustCompile(`^[\p{L}_\\][\p{L}\p{N}_.]*$`)
+
+// validateTable rejects table options and column totals that cannot be
+// written.
+func validateTable(meta types.MetaData) error {
+	for _, col := range meta.Columns {
+		if col.Total == "" {
+			continue
+		}
+		if _, ok := totalFunctions[strings.ToLower(col.Total)]; !ok {
+			return fmt.Errorf("%w: column %q has unknown total %q", types.ErrInvalidMeta, col.Name, col.Total)
+		}
+	}
+	if meta.Table == nil {
+		return nil
+	}
+
+	if name := meta.Table.Name; name != "" {
+		_, _, err := excelize.CellNameToCoordinates(name)
+		if utf8.RuneCountInString(name) > 255 || !tableName.MatchString(name) || err == nil {
+			return fmt.Errorf("%w: invalid table name %q", types.ErrInvalidMeta, name)
+		}
+	}
+
+	// Table columns are named after the headers, which Excel requires to be
+	// unique.
+	seen := make(map[string]bool, len(meta.Columns))
+	for _, header := range createHeaders(meta.Columns) {
+		key := strings.ToLower(header)
+		if strings.TrimSpace(header) == "" || seen[key] {
+			return fmt.Errorf("%w: table headers must be unique and not blank, got %q", types.ErrInvalidMeta, header)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// resolveTables gives every table a copy of its options with a name, using
+// Table1, Table2 and so on for unnamed tables. Table names are unique
+// within the workbook.
+func resolveTables(sheets []types.Sheet) error {
+	seen := make(map[string]bool)
+	for _, sheet := range sheets {
+		if sheet.Meta.Table != nil && sheet.Meta.Table.Name != "" {
+			key := strings.ToLower(sheet.Meta.Table.Name)
+			if seen[key] {
+				return fmt.Errorf("%w: duplicate table name %q", types.ErrInvalidMeta, sheet.Meta.Table.Name)
+			}
+			seen[key] = true
+		}
+	}
+
+	next := 1
+	for i, sheet := range sheets {
+		if sheet.Meta.Table == nil {
+			continue
+		}
+		table := *sheet.Meta.Table
+		for table.Name == "" {
+			name := "Table" + strconv.Itoa(next)
+			next++
+			if !seen[strings.ToLower(name)] {
+				table.Name = name
+			}
+		}
+		sheets[i].Meta.Table = &table
+	}
+	return nil
+}
+
+// newTable returns the table covering the header row and the data rows up
+// to lastRow. Excel tables need at least one data row, so an empty table
+// still spans row 2.
+func newTable(opts *types.TableOptions, columns, lastRow int) *excelize.Table {
+	style := opts.Style
+	if style == "" {
+		style = "TableStyleMedium2"
+	}
+	return &excelize.Table{
+		Range:             "A1:" + colIndexToName(columns-1) + strconv.Itoa(max(lastRow, 2)),
+		Name:              opts.Name,
+		StyleName:         style,
+		ShowRowStripes:    opts.BandedRows,
+		ShowColumnStripes: opts.BandedColumns,
+	}
+}
+
+// totalsRow returns the cells of the totals row for data that ends at
+// lastRow, or nil when no column has a total. The row holds SUBTOTAL
+// formulas, since excelize cannot mark a row as a table's own totals row.
+func totalsRow(meta []types.ColumnMeta, lastRow int, styles *ExcelStyles) []excelize.Cell {
+	var hasTotals bool
+	for _, col := range meta {
+		hasTotals = hasTotals || col.Total != ""
+	}
+	if !hasTotals {
+		return nil
+	}
+
+	lastRow = max(lastRow, 2)
+	cells := make([]excelize.Cell, len(meta))
+	for colIndex, col := range meta {
+		function := totalFunctions[strings.ToLower(col.Total)]
+		switch {
+		case function == 0 && colIndex == 0:
+			cells[colIndex] = excelize.Cell{StyleID: styles.HeaderStyle, Value: "Total"}
+			continue
+		case function == 0:
+			continue
+		}
+
+		colName := colIndexToName(colIndex)
+		cells[colIndex].Formula = fmt.Sprintf("SUBTOTAL(%d,%s2:%s%d)", function, colName, colName, lastRow)
+		cells[colIndex].StyleID = columnStyle(col, styles)
+		if !isHidden(col) && (function == totalFunctions["count"] || function == totalFunctions["count_numbers"]) {
+			cells[colIndex].StyleID = styles.IntStyle
+		}
+	}
+	return cells
+}
+
+// totalsRowNumber returns the row of the totals row. Below a table it
+// follows the data directly. Otherwise a blank row keeps it out of the
+// region the autofilter sorts and filters.
+func totalsRowNumber(lastRow int, table bool) int {
+	if table {
+		return max(lastRow, 2) + 1
+	}
+	return max(lastRow, 2) + 2
+}
+
+// setTotals writes the totals row cells to row.
+func setTotals(f *excelize.File, sheetName string, cells []excelize.Cell, row int) error {
+	for colIndex, cell := range cells {
+		if cell.Value == nil && cell.Formula == "" {
+			continue
+		}
+		cellRef := colIndexToName(colIndex) + strconv.Itoa(row)
+		if cell.Formula != "" {
+			if err := f.SetCellFormula(sheetName, cellRef, cell.Formula); err != nil {
+				return err
+			}
+		} else if err := f.SetCellValue(sheetName, cellRef, cell.Value); err != nil {
+			return err
+		}
+		if err := f.SetCellStyle(sheetName, cellRef, cellRef, cell.StyleID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,488 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jagac/excelify/internal/types"
+	"github.com/xuri/efp"
+	"github.com/xuri/excelize/v2"
+)
+
+// placeholder matches tokens such as {{customer.name}} and {{items[].sku}}.
+var placeholder = regexp.MustCompile(`\{\{\s*([^{}]*?)\s*\}\}`)
+
+// maxTemplateRows bounds the number of rows a repeating row expands to.
+const maxTemplateRows = 10000
+
+// defaultRowHeight is the height of rows without a height of their own.
+const defaultRowHeight = 15
+
+// FillTemplate fills the placeholders and named cells of an XLSX template
+// with values and writes the result to w. A row holding placeholders such
+// as {{items[].sku}} is repeated once for every element of the items array.
+func (c *ConverterImpl) FillTemplate(w io.Writer, template io.Reader, values map[string]interface{}, opts types.ExcelOptions) error {
+	f, err := excelize.OpenReader(template)
+	if err != nil {
+		return fmt.Errorf("%w: %v", types.ErrInvalidTemplate, err)
+	}
+	defer f.Close()
+
+	t := &templateFiller{f: f, values: values, delimiter: opts.ArrayDelimiter}
+	for _, sheetName := range f.GetSheetList() {
+		if err := t.fillSheet(sheetName); err != nil {
+			return fmt.Errorf("sheet %q: %w", sheetName, err)
+		}
+	}
+	if err := t.fillNames(); err != nil {
+		return err
+	}
+
+	return f.Write(w)
+}
+
+type templateFiller struct {
+	f         *excelize.File
+	values    map[string]interface{}
+	delimiter string
+}
+
+// templateRow is a row of a template that is repeated for every element
+// of the array at path.
+type templateRow struct {
+	row  int
+	path string
+	// values are the raw values of the row, and cells the text of the cells
+	// holding placeholders by cell reference.
+	values []string
+	cells  map[string]string
+}
+
+// fillSheet fills the placeholders of a sheet, repeating rows that refer to
+// array elements from the bottom up so that the rows still to be handled
+// keep their numbers.
+func (t *templateFiller) fillSheet(sheetName string) error {
+	rows, err := t.f.GetRows(sheetName, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return err
+	}
+
+	var repeated []templateRow
+	for rowIndex, row := range rows {
+		cells := make(map[string]string)
+		var arrayPath string
+		for colIndex, text := range row {
+			if !placeholder.MatchString(text) {
+				continue
+			}
+			cellRef, err := excelize.CoordinatesToCellName(colIndex+1, rowIndex+1)
+			if err != nil {
+				return err
+			}
+			if formula, _ := t.f.GetCellFormula(sheetName, cellRef); formula != "" {
+				continue
+			}
+			cells[cellRef] = text
+
+			for _, match := range placeholder.FindAllStringSubmatch(text, -1) {
+				path, _, isElement := strings.Cut(match[1], "[]")
+				if !isElement {
+					continue
+				}
+				if arrayPath != "" && arrayPath != path {
+					return fmt.Errorf("%w: row %d repeats both %q and %q", types.ErrInvalidTemplate, rowIndex+1, arrayPath, path)
+				}
+				arrayPath = path
+			}
+		}
+
+		if arrayPath != "" {
+			repeated = append(repeated, templateRow{row: rowIndex + 1, path: arrayPath, values: row, cells: cells})
+			continue
+		}
+		for cellRef, text := range cells {
+			if err := t.fillCell(sheetName, cellRef, text, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := len(repeated) - 1; i >= 0; i-- {
+		if err := t.repeatRow(sheetName, repeated[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// repeatRow inserts a copy of a template row below it for every element of
+// its array after the first, and fills each row from its element. The row
+// is removed when the array is missing or empty.
+func (t *templateFiller) repeatRow(sheetName string, tr templateRow) error {
+	value, _ := lookupValue(t.values, tr.path)
+	elements, _ := value.([]interface{})
+	if len(elements) == 0 {
+		return t.removeRow(sheetName, tr.row)
+	}
+	if len(elements) > maxTemplateRows {
+		return fmt.Errorf("%w: %q has %d elements, more than the %d rows a template can repeat", types.ErrInvalidTemplate, tr.path, len(elements), maxTemplateRows)
+	}
+
+	if copies := len(elements) - 1; copies > 0 {
+		if err := t.f.InsertRows(sheetName, tr.row+1, copies); err != nil {
+			return err
+		}
+		if err := t.copyRow(sheetName, tr, copies); err != nil {
+			return err
+		}
+	}
+
+	for i, element := range elements {
+		for cellRef, text := range tr.cells {
+			col, _, err := excelize.CellNameToCoordinates(cellRef)
+			if err != nil {
+				return err
+			}
+			copyRef, err := excelize.CoordinatesToCellName(col, tr.row+i)
+			if err != nil {
+				return err
+			}
+			if err := t.fillCell(sheetName, copyRef, text, element); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// copyRow copies the styles, height, merged cells, constant values and
+// formulas of a template row to the copies rows inserted below it, and
+// extends the validations and conditional formats of the row over them.
+// Relative references in formulas move down with each copy.
+func (t *templateFiller) copyRow(sheetName string, tr templateRow, copies int) error {
+	lastRow := tr.row + copies
+	for colIndex, text := range tr.values {
+		cellRef, err := excelize.CoordinatesToCellName(colIndex+1, tr.row)
+		if err != nil {
+			return err
+		}
+		style, err := t.f.GetCellStyle(sheetName, cellRef)
+		if err != nil {
+			return err
+		}
+		formula, err := t.f.GetCellFormula(sheetName, cellRef)
+		if err != nil {
+			return err
+		}
+		cellType, err := t.f.GetCellType(sheetName, cellRef)
+		if err != nil {
+			return err
+		}
+		_, isPlaceholder := tr.cells[cellRef]
+
+		var value interface{} = text
+		if number, err := strconv.ParseFloat(text, 64); err == nil && (cellType == excelize.CellTypeNumber || cellType == excelize.CellTypeUnset) {
+			value = number
+		} else if cellType == excelize.CellTypeBool {
+			value = text == "1" || strings.EqualFold(text, "true")
+		}
+
+		for row := tr.row + 1; row <= lastRow; row++ {
+			copyRef, err := excelize.CoordinatesToCellName(colIndex+1, row)
+			if err != nil {
+				return err
+			}
+			if style != 0 {
+				if err := t.f.SetCellStyle(sheetName, copyRef, copyRef, style); err != nil {
+					return err
+				}
+			}
+			switch {
+			case formula != "":
+				if err := t.f.SetCellFormula(sheetName, copyRef, shiftFormula(formula, row-tr.row)); err != nil {
+					return err
+				}
+			case !isPlaceholder && text != "":
+				if err := t.f.SetCellValue(sheetName, copyRef, value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if height, err := t.f.GetRowHeight(sheetName, tr.row); err == nil && height != defaultRowHeight {
+		for row := tr.row + 1; row <= lastRow; row++ {
+			if err := t.f.SetRowHeight(sheetName, row, height); err != nil {
+				return err
+			}
+		}
+	}
+
+	mergeCells, err := t.f.GetMergeCells(sheetName)
+	if err != nil {
+		return err
+	}
+	for _, mergeCell := range mergeCells {
+		startCol, startRow, err := excelize.CellNameToCoordinates(mergeCell.GetStartAxis())
+		if err != nil {
+			return err
+		}
+		endCol, endRow, err := excelize.CellNameToCoordinates(mergeCell.GetEndAxis())
+		if err != nil {
+			return err
+		}
+		if startRow != tr.row || endRow != tr.row {
+			continue
+		}
+		for row := tr.row + 1; row <= lastRow; row++ {
+			start, _ := excelize.CoordinatesToCellName(startCol, row)
+			end, _ := excelize.CoordinatesToCellName(endCol, row)
+			if err := t.f.MergeCell(sheetName, start, end); err != nil {
+				return err
+			}
+		}
+	}
+
+	conditionalFormats, err := t.f.GetConditionalFormats(sheetName)
+	if err != nil {
+		return err
+	}
+	for sqref, opts := range conditionalFormats {
+		extended, ok := extendRowRanges(sqref, tr.row, lastRow)
+		if !ok {
+			continue
+		}
+		if err := t.f.UnsetConditionalFormat(sheetName, sqref); err != nil {
+			return err
+		}
+		if err := t.f.SetConditionalFormat(sheetName, extended, opts); err != nil {
+			return err
+		}
+	}
+
+	validations, err := t.f.GetDataValidations(sheetName)
+	if err != nil {
+		return err
+	}
+	for _, dv := range validations {
+		extended, ok := extendRowRanges(dv.Sqref, tr.row, lastRow)
+		if !ok {
+			continue
+		}
+		if err := t.f.DeleteDataValidation(sheetName, dv.Sqref); err != nil {
+			return err
+		}
+		dv.Sqref = extended
+		if err := t.f.AddDataValidation(sheetName, dv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeRow removes a row of a sheet. excelize drops every validation and
+// conditional format that spans a single row when any row is removed, so
+// those of other rows are added back.
+func (t *templateFiller) removeRow(sheetName string, row int) error {
+	validations, err := t.f.GetDataValidations(sheetName)
+	if err != nil {
+		return err
+	}
+	conditionalFormats, err := t.f.GetConditionalFormats(sheetName)
+	if err != nil {
+		return err
+	}
+	if err := t.f.RemoveRow(sheetName, row); err != nil {
+		return err
+	}
+
+	keptValidations, err := t.f.GetDataValidations(sheetName)
+	if err != nil {
+		return err
+	}
+	kept := make(map[string]bool, len(keptValidations))
+	for _, dv := range keptValidations {
+		kept[dv.Sqref] = true
+	}
+	for _, dv := range validations {
+		sqref, ok := removeRowFromRanges(dv.Sqref, row)
+		if !ok || kept[sqref] {
+			continue
+		}
+		dv.Sqref = sqref
+		if err := t.f.AddDataValidation(sheetName, dv); err != nil {
+			return err
+		}
+	}
+
+	keptFormats, err := t.f.GetConditionalFormats(sheetName)
+	if err != nil {
+		return err
+	}
+	for sqref, opts := range conditionalFormats {
+		sqref, ok := removeRowFromRanges(sqref, row)
+		if _, isKept := keptFormats[sqref]; !ok || isKept {
+			continue
+		}
+		if err := t.f.SetConditionalFormat(sheetName, sqref, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeRowFromRanges returns sqref as it is after row is removed, or false
+// when nothing of it is left.
+func removeRowFromRanges(sqref string, row int) (string, bool) {
+	var ranges []string
+	for _, rng := range strings.Fields(sqref) {
+		first, last, isRange := strings.Cut(rng, ":")
+		if !isRange {
+			last = first
+		}
+		firstCol, firstRow, err := excelize.CellNameToCoordinates(first)
+		if err != nil {
+			return "", false
+		}
+		lastCol, lastRow, err := excelize.CellNameToCoordinates(last)
+		if err != nil {
+			return "", false
+		}
+		if firstRow == row && lastRow == row {
+			continue
+		}
+		if firstRow > row {
+			firstRow--
+		}
+		if lastRow >= row {
+			lastRow--
+		}
+		ranges = append(ranges, colIndexToName(firstCol-1)+strconv.Itoa(firstRow)+":"+colIndexToName(lastCol-1)+strconv.Itoa(lastRow))
+	}
+	return strings.Join(ranges, " "), len(ranges) > 0
+}
+
+// extendRowRanges stretches the ranges of sqref down to lastRow, if all of
+// them lie within row.
+func extendRowRanges(sqref string, row, lastRow int) (string, bool) {
+	ranges := strings.Fields(sqref)
+	for i, rng := range ranges {
+		first, last, isRange := strings.Cut(rng, ":")
+		if !isRange {
+			last = first
+		}
+		_, firstRow, err := excelize.CellNameToCoordinates(first)
+		if err != nil || firstRow != row {
+			return "", false
+		}
+		lastCol, lastRowOfRange, err := excelize.CellNameToCoordinates(last)
+		if err != nil || lastRowOfRange != row {
+			return "", false
+		}
+		ranges[i] = first + ":" + colIndexToName(lastCol-1) + strconv.Itoa(lastRow)
+	}
+	return strings.Join(ranges, " "), len(ranges) > 0
+}
+
+// cellReference splits a cell reference such as B6 or $B$6 into its column
+// and row, noting whether the row is absolute.
+var cellReference = regexp.MustCompile(`^(\$?[A-Za-z]{1,3})(\$?)([0-9]+)$`)
+
+// rowReference matches the relative row references of whole-row ranges.
+var rowReference = regexp.MustCompile(`^([0-9]+)$`)
+
+// shiftFormula moves the relative row references of a formula down by
+// offset rows, as Excel does when a cell is copied.
+func shiftFormula(formula string, offset int) string {
+	ps := efp.ExcelParser()
+	ps.Parse(formula)
+	for i, token := range ps.Tokens.Items {
+		if token.TType != efp.TokenTypeOperand || token.TSubType != efp.TokenSubTypeRange {
+			continue
+		}
+		prefix, ref := "", token.TValue
+		if separator := strings.LastIndex(ref, "!"); separator != -1 {
+			prefix, ref = ref[:separator+1], ref[separator+1:]
+		}
+		parts := strings.Split(ref, ":")
+		for j, part := range parts {
+			if match := cellReference.FindStringSubmatch(part); match != nil && match[2] == "" {
+				row, _ := strconv.Atoi(match[3])
+				parts[j] = match[1] + strconv.Itoa(row+offset)
+			} else if rowReference.MatchString(part) {
+				row, _ := strconv.Atoi(part)
+				parts[j] = strconv.Itoa(row + offset)
+			}
+		}
+		ps.Tokens.Items[i].TValue = prefix + strings.Join(parts, ":")
+	}
+	return ps.Render()
+}
+
+// fillCell replaces the placeholders in text and writes the result to the
+// cell. A cell holding nothing but a placeholder gets the value as is, so
+// numbers and booleans keep their type and the cell's number format.
+func (t *templateFiller) fillCell(sheetName, cellRef, text string, element interface{}) error {
+	if match := placeholder.FindStringSubmatch(text); match != nil && match[0] == text {
+		return t.f.SetCellValue(sheetName, cellRef, flattenValue(t.resolve(match[1], element), t.delimiter))
+	}
+
+	filled := placeholder.ReplaceAllStringFunc(text, func(token string) string {
+		path := placeholder.FindStringSubmatch(token)[1]
+		return elementText(flattenValue(t.resolve(path, element), t.delimiter))
+	})
+	return t.f.SetCellStr(sheetName, cellRef, filled)
+}
+
+// resolve returns the value a placeholder path refers to. Paths such as
+// items[].sku are read from element, and items[] is the element itself.
+// Missing values are nil.
+func (t *templateFiller) resolve(path string, element interface{}) interface{} {
+	_, elementPath, isElement := strings.Cut(path, "[]")
+	if !isElement {
+		value, _ := lookupValue(t.values, path)
+		return value
+	}
+
+	elementPath = strings.TrimPrefix(elementPath, ".")
+	if elementPath == "" {
+		return element
+	}
+	object, ok := element.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	value, _ := lookupValue(object, elementPath)
+	return value
+}
+
+// fillNames writes values to the cells of defined names such as
+// customer.name that refer to a single cell and match a path in values.
+func (t *templateFiller) fillNames() error {
+	for _, name := range t.f.GetDefinedName() {
+		value, ok := lookupValue(t.values, name.Name)
+		if !ok {
+			continue
+		}
+
+		reference := strings.TrimPrefix(name.RefersTo, "=")
+		separator := strings.LastIndex(reference, "!")
+		if separator == -1 {
+			continue
+		}
+		sheetName := strings.Trim(reference[:separator], "'")
+		sheetName = strings.ReplaceAll(sheetName, "''", "'")
+		cellRef := strings.ReplaceAll(reference[separator+1:], "$", "")
+		if _, _, err := excelize.CellNameToCoordinates(cellRef); err != nil {
+			continue
+		}
+
+		if err := t.f.SetCellValue(sheetName, cellRef, flattenValue(value, t.delimiter)); err != nil {
+			return fmt.Errorf("%w: name %q: %v", types.ErrInvalidTemplate, name.Name, err)
+		}
+	}
+	return nil
+}
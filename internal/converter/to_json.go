@@ -0,0 +1,218 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jagac/excelify/internal/types"
+	"github.com/xuri/excelize/v2"
+)
+
+func (c *ConverterImpl) ConvertToJson(f *excelize.File, opts types.JsonOptions) ([]byte, error) {
+	var report *importReport
+	if opts.Meta != nil {
+		if err := validateColumns(opts.Meta.Columns); err != nil {
+			return nil, err
+		}
+		// Values are checked against the schema from their cell types.
+		opts.Typed = true
+		report = &importReport{}
+	}
+
+	var result interface{}
+	if opts.AllSheets {
+		sheets := make(map[string][]map[string]interface{})
+		for _, sheetName := range f.GetSheetList() {
+			rows, err := sheetToJson(f, sheetName, opts, report)
+			if err != nil {
+				return nil, err
+			}
+			sheets[sheetName] = rows
+		}
+		result = sheets
+	} else {
+		sheetName, err := selectSheet(f, opts.Sheet)
+		if err != nil {
+			return nil, err
+		}
+		if result, err = sheetToJson(f, sheetName, opts, report); err != nil {
+			return nil, err
+		}
+	}
+
+	if report != nil {
+		if err := report.err(); err != nil {
+			return nil, err
+		}
+	}
+
+	jsonData, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	return jsonData, nil
+}
+
+// selectSheet resolves a sheet given by name or by zero-based index. An
+// empty selector picks the first sheet.
+func selectSheet(f *excelize.File, selector string) (string, error) {
+	sheetList := f.GetSheetList()
+	if selector == "" {
+		return sheetList[0], nil
+	}
+
+	for _, sheetName := range sheetList {
+		if sheetName == selector {
+			return sheetName, nil
+		}
+	}
+
+	if index, err := strconv.Atoi(selector); err == nil && index >= 0 && index < len(sheetList) {
+		return sheetList[index], nil
+	}
+
+	return "", fmt.Errorf("%w: %s", types.ErrSheetNotFound, selector)
+}
+
+// sheetToJson reads the rows of a sheet. With a schema in opts, rows are
+// checked against it and problems are added to report.
+func sheetToJson(f *excelize.File, sheetName string, opts types.JsonOptions, report *importReport) ([]map[string]interface{}, error) {
+	rows, err := f.GetRows(sheetName, excelize.Options{RawCellValue: opts.Typed})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows: %w", err)
+	}
+
+	result := []map[string]interface{}{}
+	window, err := resolveWindow(rows, opts)
+	if err != nil {
+		return nil, err
+	}
+	if window.headerRow >= len(rows) {
+		if report != nil {
+			newImportSchema(sheetName, opts.Meta.Columns, nil, 0, false, report)
+		}
+		return result, nil
+	}
+
+	var reader *cellReader
+	if opts.Typed {
+		if reader, err = newCellReader(f, sheetName); err != nil {
+			return nil, fmt.Errorf("failed to read workbook properties: %w", err)
+		}
+	}
+
+	headers := resolveHeaders(window.clip(rows[window.headerRow]), window.firstCol, opts.HeaderKeys)
+	var schema *importSchema
+	if report != nil {
+		schema = newImportSchema(sheetName, opts.Meta.Columns, headers, window.firstCol, reader.date1904, report)
+	}
+	// Cells beyond the header row get a header named after their column,
+	// kept by position so that later rows put their cells under it too.
+	headerCount := len(headers)
+	extraHeaders := make(map[int]string)
+	firstRow, lastRow := window.dataRows(rows)
+	for rowIndex := firstRow; rowIndex <= lastRow; rowIndex++ {
+		rowData := make(map[string]interface{})
+		if opts.Typed {
+			// Typed output reports every column, with null for empty cells.
+			for _, header := range headers {
+				rowData[header] = nil
+			}
+		}
+		for i, cell := range window.clip(rows[rowIndex]) {
+			colIndex := window.firstCol + i
+			cellRef, err := excelize.CoordinatesToCellName(colIndex+1, rowIndex+1)
+			if err != nil {
+				return nil, err
+			}
+
+			var header string
+			if i < headerCount {
+				header = headers[i]
+			} else if header = extraHeaders[i]; header == "" {
+				if cell == "" {
+					continue
+				}
+				switch opts.ExtraCells {
+				case types.ExtraCellsDrop:
+					continue
+				case types.ExtraCellsError:
+					return nil, &types.SheetError{Sheet: sheetName, Cell: cellRef, Reason: "cell has no header"}
+				default:
+					header = uniqueHeader(generatedHeader(colIndex), headers)
+					headers = append(headers, header)
+					extraHeaders[i] = header
+				}
+			}
+
+			if !opts.Typed {
+				rowData[header] = cell
+				continue
+			}
+
+			value, err := reader.value(cellRef, cell)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read cell %s: %w", cellRef, err)
+			}
+			rowData[header] = value
+		}
+		keys := headers
+		if schema != nil {
+			// Rows without any value, such as unused entry rows, are skipped.
+			if isBlankRow(rowData) {
+				continue
+			}
+			rowData = schema.apply(rowData, rowIndex-firstRow, rowIndex+1)
+			keys = schema.keys(headers)
+		}
+		if opts.Unflatten {
+			rowData = unflattenRow(rowData, keys)
+		}
+		result = append(result, rowData)
+	}
+
+	return result, nil
+}
+
+// resolveHeaders turns the header row, starting at column firstCol, into
+// unique keys. Labels found in headerKeys are replaced by their key, blank
+// headers are named after their column and repeated names get a numeric
+// suffix.
+func resolveHeaders(row []string, firstCol int, headerKeys map[string]string) []string {
+	headers := make([]string, 0, len(row))
+	for i, header := range row {
+		if key, ok := headerKeys[header]; ok {
+			header = key
+		}
+		if strings.TrimSpace(header) == "" {
+			header = generatedHeader(firstCol + i)
+		}
+		headers = append(headers, uniqueHeader(header, headers))
+	}
+	return headers
+}
+
+func generatedHeader(index int) string {
+	return "column_" + colIndexToName(index)
+}
+
+func uniqueHeader(header string, taken []string) string {
+	candidate := header
+	for suffix := 2; slices.Contains(taken, candidate); suffix++ {
+		candidate = header + "_" + strconv.Itoa(suffix)
+	}
+	return candidate
+}
+
+func isBlankRow(row map[string]interface{}) bool {
+	for _, value := range row {
+		if value != nil {
+			return false
+		}
+	}
+	return true
+}
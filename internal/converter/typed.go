@@ -0,0 +1,172 @@
+package converter
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+type dateKind int
+
+const (
+	notDate dateKind = iota
+	dateOnly
+	timeOnly
+	dateTime
+	// elapsed formats such as [h]:mm:ss show a duration, whose hours go
+	// beyond a day.
+	elapsed
+)
+
+// cellReader turns raw cell values into typed JSON values based on the cell
+// type and the number format of the cell style.
+type cellReader struct {
+	f         *excelize.File
+	sheetName string
+	date1904  bool
+	kinds     map[int]dateKind
+}
+
+func newCellReader(f *excelize.File, sheetName string) (*cellReader, error) {
+	props, err := f.GetWorkbookProps()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cellReader{
+		f:         f,
+		sheetName: sheetName,
+		date1904:  props.Date1904 != nil && *props.Date1904,
+		kinds:     make(map[int]dateKind),
+	}, nil
+}
+
+// value converts the raw value of cell. Empty cells become nil, booleans and
+// numbers keep their type and date formatted numbers become ISO-8601 strings.
+// Numbers in elapsed time formats become durations such as "36:00:00".
+func (r *cellReader) value(cell, raw string) (interface{}, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	cellType, err := r.f.GetCellType(r.sheetName, cell)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cellType {
+	case excelize.CellTypeBool:
+		return raw == "1" || strings.EqualFold(raw, "true"), nil
+	case excelize.CellTypeNumber, excelize.CellTypeUnset:
+		number, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return raw, nil
+		}
+
+		kind, err := r.dateKind(cell)
+		if err != nil || kind == notDate {
+			return number, err
+		}
+		if kind == elapsed {
+			return formatDuration(number * 86400), nil
+		}
+
+		t, err := excelize.ExcelDateToTime(number, r.date1904)
+		if err != nil {
+			return number, nil
+		}
+		switch kind {
+		case dateOnly:
+			return t.Format("2006-01-02"), nil
+		case timeOnly:
+			return t.Format("15:04:05"), nil
+		default:
+			return t.Format("2006-01-02T15:04:05"), nil
+		}
+	default:
+		return raw, nil
+	}
+}
+
+func (r *cellReader) dateKind(cell string) (dateKind, error) {
+	styleID, err := r.f.GetCellStyle(r.sheetName, cell)
+	if err != nil {
+		return notDate, err
+	}
+	if kind, ok := r.kinds[styleID]; ok {
+		return kind, nil
+	}
+
+	style, err := r.f.GetStyle(styleID)
+	if err != nil {
+		return notDate, err
+	}
+
+	kind := builtInDateKind(style.NumFmt)
+	if style.CustomNumFmt != nil {
+		kind = customDateKind(*style.CustomNumFmt)
+	}
+	r.kinds[styleID] = kind
+	return kind, nil
+}
+
+func builtInDateKind(numFmt int) dateKind {
+	switch {
+	case numFmt >= 14 && numFmt <= 17:
+		return dateOnly
+	case numFmt == 46:
+		return elapsed
+	case numFmt >= 18 && numFmt <= 21, numFmt == 45, numFmt == 47:
+		return timeOnly
+	case numFmt == 22:
+		return dateTime
+	case numFmt >= 27 && numFmt <= 36, numFmt >= 50 && numFmt <= 58:
+		return dateOnly
+	}
+	return notDate
+}
+
+// customDateKind inspects a format code for date and time tokens, skipping
+// quoted text, escaped characters and bracketed sections such as colors.
+// Formats with elapsed time sections like [h] are elapsed.
+func customDateKind(format string) dateKind {
+	var hasDate, hasTime, hasElapsed bool
+	inQuotes := false
+	for i := 0; i < len(format); i++ {
+		ch := format[i]
+		switch {
+		case ch == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case ch == '\\':
+			i++
+		case ch == '[':
+			end := strings.IndexByte(format[i:], ']')
+			if end < 0 {
+				return notDate
+			}
+			section := strings.ToLower(format[i+1 : i+end])
+			if section != "" && strings.Trim(section, "hms") == "" {
+				hasElapsed = true
+			}
+			i += end
+		case ch == 'y' || ch == 'Y' || ch == 'd' || ch == 'D':
+			hasDate = true
+		case ch == 'h' || ch == 'H' || ch == 's' || ch == 'S':
+			hasTime = true
+		}
+	}
+
+	switch {
+	case hasElapsed:
+		return elapsed
+	case hasDate && hasTime:
+		return dateTime
+	case hasDate:
+		return dateOnly
+	case hasTime:
+		return timeOnly
+	}
+	return notDate
+}
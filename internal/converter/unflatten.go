@@ -0,0 +1,82 @@
+package converter
+
+// maxUnflattenIndex bounds the array indexes read from headers, so a header
+// such as "tags[99999999]" cannot allocate a huge array.
+const maxUnflattenIndex = 10000
+
+// unflattenRow rebuilds nested objects and arrays from keys such as
+// "address.city" and "tags[0]", taking the keys in header order. Keys that
+// are not valid paths, or that clash with a value already placed, are kept
+// as they are.
+func unflattenRow(row map[string]interface{}, headers []string) map[string]interface{} {
+	result := make(map[string]interface{}, len(row))
+	var clashes []string
+	for _, header := range headers {
+		value, ok := row[header]
+		if !ok {
+			continue
+		}
+
+		segments, err := parsePath(header)
+		if err != nil {
+			clashes = append(clashes, header)
+			continue
+		}
+		if _, ok := setPath(result, segments, value); !ok {
+			clashes = append(clashes, header)
+		}
+	}
+
+	for _, header := range clashes {
+		if _, taken := result[header]; !taken {
+			result[header] = row[header]
+		}
+	}
+	return result
+}
+
+// setPath places value at segments below node, creating the objects and
+// arrays along the way. It reports false when the path runs into a value of
+// another kind or one that is already set.
+func setPath(node interface{}, segments []pathSegment, value interface{}) (interface{}, bool) {
+	if len(segments) == 0 {
+		if node != nil {
+			return node, false
+		}
+		return value, true
+	}
+
+	segment := segments[0]
+	if segment.key != "" {
+		object, ok := node.(map[string]interface{})
+		if node == nil {
+			object, ok = make(map[string]interface{}), true
+		}
+		if !ok {
+			return node, false
+		}
+		child, ok := setPath(object[segment.key], segments[1:], value)
+		if !ok {
+			return node, false
+		}
+		object[segment.key] = child
+		return object, true
+	}
+
+	array, ok := node.([]interface{})
+	if node == nil {
+		ok = true
+	}
+	if !ok || segment.index > maxUnflattenIndex {
+		return node, false
+	}
+	for len(array) <= segment.index {
+		array = append(array, nil)
+	}
+	child, ok := setPath(array[segment.index], segments[1:], value)
+	if !ok {
+		return node, false
+	}
+	array[segment.index] = child
+	return array, true
+}
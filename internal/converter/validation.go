@@ -0,0 +1,262 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jagac/excelify/internal/types"
+	"github.com/xuri/excelize/v2"
+)
+
+// maxEntryRows bounds the number of empty rows added for new entries.
+const maxEntryRows = 10000
+
+// maxInlineList is the longest dropdown list Excel accepts inline. Longer
+// lists are written to a hidden sheet.
+const maxInlineList = 255
+
+// rangeTypes maps the column types that accept a min and max to the type of
+// data validation that enforces them.
+var rangeTypes = map[string]excelize.DataValidationType{
+	"INTEGER":    excelize.DataValidationTypeWhole,
+	"FLOAT":      excelize.DataValidationTypeDecimal,
+	"PERCENTAGE": excelize.DataValidationTypeDecimal,
+	"CURRENCY":   excelize.DataValidationTypeDecimal,
+	"DURATION":   excelize.DataValidationTypeDecimal,
+	"DATE":       excelize.DataValidationTypeDate,
+	"DATETIME":   excelize.DataValidationTypeDate,
+	"TIME":       excelize.DataValidationTypeTime,
+}
+
+// validateValidation rejects a column validation that cannot be written.
+func validateValidation(col types.ColumnMeta) error {
+	v := col.Validation
+	if v == nil {
+		return nil
+	}
+
+	hasRange := v.Min != nil || v.Max != nil
+	var kinds int
+	for _, set := range []bool{len(v.Enum) > 0, hasRange, v.MaxLength != 0} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds > 1 {
+		return fmt.Errorf("%w: column %q: validation takes only one of enum, min and max, or max_length", types.ErrInvalidMeta, col.Name)
+	}
+	if v.MaxLength < 0 {
+		return fmt.Errorf("%w: column %q: max_length must not be negative", types.ErrInvalidMeta, col.Name)
+	}
+	if !hasRange {
+		return nil
+	}
+
+	if _, ok := rangeTypes[col.Type]; !ok {
+		return fmt.Errorf("%w: column %q: min and max are not supported for %s columns", types.ErrInvalidMeta, col.Name, col.Type)
+	}
+	min, max, err := validationBounds(col)
+	if err != nil {
+		return fmt.Errorf("%w: column %q: %v", types.ErrInvalidMeta, col.Name, err)
+	}
+	if min != nil && max != nil && *min > *max {
+		return fmt.Errorf("%w: column %q: min is greater than max", types.ErrInvalidMeta, col.Name)
+	}
+	return nil
+}
+
+// validationBounds converts the min and max of a column to the numbers
+// Excel compares cell values with.
+func validationBounds(col types.ColumnMeta) (min, max *float64, err error) {
+	if col.Validation.Min != nil {
+		if min, err = validationBound(col.Validation.Min, col); err != nil {
+			return nil, nil, fmt.Errorf("invalid min: %w", err)
+		}
+	}
+	if col.Validation.Max != nil {
+		if max, err = validationBound(col.Validation.Max, col); err != nil {
+			return nil, nil, fmt.Errorf("invalid max: %w", err)
+		}
+	}
+	return min, max, nil
+}
+
+func validationBound(value interface{}, col types.ColumnMeta) (*float64, error) {
+	var number float64
+	switch col.Type {
+	case "DATE", "DATETIME":
+		t, err := toTime(value, col, defaultDatetimeLayouts)
+		if err != nil {
+			return nil, err
+		}
+		if col.Type == "DATE" {
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		number = excelTime(t)
+	case "TIME":
+		strValue, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a time such as 09:00, got %v", value)
+		}
+		var err error
+		if number, err = toTimeOfDay(strValue); err != nil {
+			return nil, err
+		}
+	case "DURATION":
+		duration, err := toDuration(value)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if number, ok = duration.(float64); !ok {
+			return nil, fmt.Errorf("expected a duration, got %v", value)
+		}
+	default:
+		switch v := value.(type) {
+		case float64:
+			number = v
+		case string:
+			var err error
+			if number, err = strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("expected a number, got %q", v)
+			}
+		default:
+			return nil, fmt.Errorf("expected a number, got %v", value)
+		}
+	}
+	return &number, nil
+}
+
+// excelTime converts the wall clock time of t to an Excel serial date.
+func excelTime(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	return wall.Sub(time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)).Hours() / 24
+}
+
+// setDataValidations adds the validation of every column to its data cells,
+// rows 2 to lastRow.
+func setDataValidations(f *excelize.File, sheetName string, meta []types.ColumnMeta, lastRow int, lists *listSheet) error {
+	if lastRow < 2 {
+		return nil
+	}
+
+	for colIndex, col := range meta {
+		v := col.Validation
+		if v == nil {
+			continue
+		}
+
+		colName := colIndexToName(colIndex)
+		dv := excelize.NewDataValidation(true)
+		dv.Sqref = colName + "2:" + colName + strconv.Itoa(lastRow)
+
+		switch {
+		case len(v.Enum) > 0:
+			// Inline lists are separated by commas, so values containing one
+			// go to the hidden sheet as well.
+			inline := strings.Join(v.Enum, ",")
+			if len(inline) <= maxInlineList && strings.Count(inline, ",") == len(v.Enum)-1 {
+				if err := dv.SetDropList(v.Enum); err != nil {
+					return err
+				}
+			} else {
+				ref, err := lists.add(f, v.Enum)
+				if err != nil {
+					return err
+				}
+				dv.SetSqrefDropList(ref)
+			}
+			dv.SetError(excelize.DataValidationErrorStyleStop, "Invalid value", "Choose one of the listed values.")
+		case v.Min != nil || v.Max != nil:
+			min, max, err := validationBounds(col)
+			if err != nil {
+				return err
+			}
+			var message string
+			switch {
+			case min != nil && max != nil:
+				err = dv.SetRange(*min, *max, rangeTypes[col.Type], excelize.DataValidationOperatorBetween)
+				message = fmt.Sprintf("Enter a value between %v and %v.", v.Min, v.Max)
+			case min != nil:
+				err = dv.SetRange(*min, "", rangeTypes[col.Type], excelize.DataValidationOperatorGreaterThanOrEqual)
+				message = fmt.Sprintf("Enter a value of at least %v.", v.Min)
+			default:
+				err = dv.SetRange(*max, "", rangeTypes[col.Type], excelize.DataValidationOperatorLessThanOrEqual)
+				message = fmt.Sprintf("Enter a value of at most %v.", v.Max)
+			}
+			if err != nil {
+				return err
+			}
+			dv.SetError(excelize.DataValidationErrorStyleStop, "Invalid value", message)
+		case v.MaxLength > 0:
+			if err := dv.SetRange(v.MaxLength, "", excelize.DataValidationTypeTextLength, excelize.DataValidationOperatorLessThanOrEqual); err != nil {
+				return err
+			}
+			dv.SetError(excelize.DataValidationErrorStyleStop, "Invalid value", fmt.Sprintf("Enter at most %d characters.", v.MaxLength))
+		default:
+			continue
+		}
+
+		if err := f.AddDataValidation(sheetName, dv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setEntryRows styles count empty rows from firstRow on like the data rows,
+// so values entered there get the column's format.
+func setEntryRows(f *excelize.File, sheetName string, meta []types.ColumnMeta, firstRow, count int, styles *ExcelStyles) error {
+	if count == 0 {
+		return nil
+	}
+
+	lastRow := strconv.Itoa(firstRow + count - 1)
+	for colIndex, col := range meta {
+		colName := colIndexToName(colIndex)
+		if err := f.SetCellStyle(sheetName, colName+strconv.Itoa(firstRow), colName+lastRow, columnStyle(col, styles)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listSheet is the hidden sheet holding dropdown values that are too long
+// for an inline list, one column per list. It is created on first use.
+type listSheet struct {
+	name    string
+	columns int
+}
+
+// add writes values to the next free column and returns a reference to
+// them.
+func (l *listSheet) add(f *excelize.File, values []string) (string, error) {
+	if l.name == "" {
+		name := "Lists"
+		for i := 2; ; i++ {
+			if index, _ := f.GetSheetIndex(name); index == -1 {
+				break
+			}
+			name = fmt.Sprintf("Lists (%d)", i)
+		}
+		if _, err := f.NewSheet(name); err != nil {
+			return "", err
+		}
+		if err := f.SetSheetVisible(name, false); err != nil {
+			return "", err
+		}
+		l.name = name
+	}
+
+	colName := colIndexToName(l.columns)
+	l.columns++
+	for i, value := range values {
+		if err := f.SetCellStr(l.name, colName+strconv.Itoa(i+1), value); err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("%s!$%s$1:$%s$%d", sheetRef(l.name), colName, colName, len(values)), nil
+}
@@ -0,0 +1,378 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jagac/excelify/internal/types"
+)
+
+// columnTypes lists the column types understood by convertValue.
+var columnTypes = map[string]bool{
+	"STRING":     true,
+	"INTEGER":    true,
+	"FLOAT":      true,
+	"DATETIME":   true,
+	"PERCENTAGE": true,
+	"BOOLEAN":    true,
+	"DATE":       true,
+	"TIME":       true,
+	"CURRENCY":   true,
+	"DURATION":   true,
+	"FORMULA":    true,
+}
+
+var currencyCode = regexp.MustCompile(`^[A-Za-z]{3}$`)
+
+// validateColumns rejects column meta the converter cannot write.
+func validateColumns(meta []types.ColumnMeta) error {
+	for _, col := range meta {
+		if !columnTypes[col.Type] {
+			return fmt.Errorf("%w: column %q has unknown type %q", types.ErrInvalidMeta, col.Name, col.Type)
+		}
+		if col.Currency != "" && !currencyCode.MatchString(col.Currency) {
+			return fmt.Errorf("%w: column %q has invalid currency code %q", types.ErrInvalidMeta, col.Name, col.Currency)
+		}
+		if col.Type == "FORMULA" && strings.TrimPrefix(col.Formula, "=") == "" {
+			return fmt.Errorf("%w: column %q has no formula", types.ErrInvalidMeta, col.Name)
+		}
+		if col.Timezone != "" {
+			if _, err := loadLocation(col.Timezone); err != nil {
+				return fmt.Errorf("%w: column %q has unknown timezone %q", types.ErrInvalidMeta, col.Name, col.Timezone)
+			}
+		}
+		if err := validateConditionalFormats(col); err != nil {
+			return err
+		}
+		if err := validateValidation(col); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// currencySymbols holds the symbols written in front of amounts for common
+// currencies. Other codes are written after the amount.
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+	"CNY": "¥",
+	"INR": "₹",
+	"KRW": "₩",
+}
+
+// currencyFormat returns the number format for amounts in the currency
+// with the given ISO 4217 code.
+func currencyFormat(code string) string {
+	code = strings.ToUpper(code)
+	amount := "#,##0.00"
+	if code == "JPY" || code == "KRW" {
+		amount = "#,##0"
+	}
+
+	if code == "" {
+		return amount
+	}
+	if symbol, ok := currencySymbols[code]; ok {
+		return `"` + symbol + `"` + amount
+	}
+	return amount + ` "` + code + `"`
+}
+
+func convertValue(value interface{}, col types.ColumnMeta, styles *ExcelStyles) (interface{}, int, error) {
+	var style int
+	var err error
+
+	switch col.Type {
+	case "STRING":
+		style = styles.TextStyle
+		if value == nil {
+			value = ""
+		}
+	case "INTEGER":
+		style = styles.IntStyle
+		if strValue, ok := value.(string); ok {
+			if strValue == "" {
+				value = ""
+			} else {
+				value, err = strconv.Atoi(strValue)
+				if err != nil {
+					return nil, 0, fmt.Errorf("failed to convert %v to integer: %w", strValue, err)
+				}
+			}
+		}
+	case "FLOAT":
+		style = styles.FloatStyle
+		if strValue, ok := value.(string); ok {
+			if strValue == "" {
+				value = ""
+			} else {
+				value, err = strconv.ParseFloat(strValue, 64)
+				if err != nil {
+					return nil, 0, fmt.Errorf("failed to convert %v to float: %w", strValue, err)
+				}
+			}
+		}
+	case "DATETIME":
+		style = styles.DatetimeStyle
+		if value != nil && value != "" {
+			value, err = toTime(value, col, defaultDatetimeLayouts)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+	case "PERCENTAGE":
+		style = styles.PercentageStyle
+	case "BOOLEAN":
+		style = styles.BooleanStyle
+		value, err = toBool(value)
+		if err != nil {
+			return nil, 0, err
+		}
+	case "DATE":
+		style = styles.DateStyle
+		if value != nil && value != "" {
+			value, err = toTime(value, col, defaultDateLayouts)
+			if err != nil {
+				return nil, 0, err
+			}
+			year, month, day := value.(time.Time).Date()
+			value = time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		}
+	case "TIME":
+		style = styles.TimeStyle
+		if strValue, ok := value.(string); ok && strValue != "" {
+			value, err = toTimeOfDay(strValue)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+	case "CURRENCY":
+		style = styles.FormatStyles[currencyFormat(col.Currency)]
+		if strValue, ok := value.(string); ok && strValue != "" {
+			value, err = strconv.ParseFloat(strValue, 64)
+			if err != nil {
+				return nil, 0, fmt.Errorf("failed to convert %v to currency: %w", strValue, err)
+			}
+		}
+	case "DURATION":
+		style = styles.DurationStyle
+		value, err = toDuration(value)
+		if err != nil {
+			return nil, 0, err
+		}
+	case "FORMULA":
+		style = styles.FormulaStyle
+		value = nil
+	default:
+		return nil, 0, fmt.Errorf("unknown column type %q", col.Type)
+	}
+
+	if col.Format != "" {
+		style = styles.FormatStyles[col.Format]
+	}
+
+	return value, style, nil
+}
+
+// formula is a cell formula, written with SetCellFormula instead of as a
+// value.
+type formula string
+
+// rowFormula fills the {row} placeholders of a FORMULA column's template
+// with the Excel row number.
+func rowFormula(template string, row int) formula {
+	return formula(strings.ReplaceAll(strings.TrimPrefix(template, "="), "{row}", strconv.Itoa(row)))
+}
+
+const (
+	unixSeconds = "unix"
+	unixMillis  = "unix_ms"
+)
+
+// defaultDatetimeLayouts are accepted by DATETIME columns without input
+// formats. Numbers are read as Unix seconds or milliseconds by magnitude.
+var defaultDatetimeLayouts = []string{
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	unixSeconds,
+	unixMillis,
+}
+
+var defaultDateLayouts = []string{"2006-01-02"}
+
+// toTime parses value with the column's input formats, falling back to the
+// given defaults. Besides Go layouts, the formats "unix" and "unix_ms" accept
+// epoch seconds and milliseconds. Values without an offset are read as UTC
+// and, when the column has a timezone, shown in that timezone.
+func toTime(value interface{}, col types.ColumnMeta, defaults []string) (time.Time, error) {
+	layouts := col.InputFormats
+	if len(layouts) == 0 {
+		layouts = defaults
+	}
+
+	var t time.Time
+	var err error
+	switch v := value.(type) {
+	case float64:
+		t, err = fromEpoch(v, layouts)
+	case string:
+		t, err = parseLayouts(v, layouts)
+	default:
+		err = fmt.Errorf("unsupported value of type %T", value)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to convert %v to %s: %w", value, strings.ToLower(col.Type), err)
+	}
+
+	if col.Timezone != "" {
+		loc, err := loadLocation(col.Timezone)
+		if err != nil {
+			return time.Time{}, err
+		}
+		t = t.In(loc)
+	}
+	return t, nil
+}
+
+func parseLayouts(value string, layouts []string) (time.Time, error) {
+	for _, layout := range layouts {
+		if layout == unixSeconds || layout == unixMillis {
+			continue
+		}
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		return fromEpoch(number, layouts)
+	}
+	return time.Time{}, fmt.Errorf("no input format matches")
+}
+
+// fromEpoch reads a Unix timestamp. When both seconds and milliseconds are
+// accepted, values beyond 1e11 (the year 5138 in seconds) are milliseconds.
+func fromEpoch(value float64, layouts []string) (time.Time, error) {
+	seconds := slices.Contains(layouts, unixSeconds)
+	millis := slices.Contains(layouts, unixMillis)
+	if seconds && millis {
+		millis = math.Abs(value) >= 1e11
+	}
+
+	switch {
+	case millis:
+		return time.UnixMilli(int64(value)).UTC(), nil
+	case seconds:
+		whole, frac := math.Modf(value)
+		return time.Unix(int64(whole), int64(frac*1e9)).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("epoch timestamps are not accepted")
+}
+
+var locations sync.Map
+
+// loadLocation caches time zones, which are looked up for every cell.
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+func toBool(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return "", nil
+		}
+		switch strings.ToLower(v) {
+		case "yes", "y":
+			return true, nil
+		case "no", "n":
+			return false, nil
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %v to boolean: %w", v, err)
+		}
+		return b, nil
+	case float64:
+		return v != 0, nil
+	}
+	return value, nil
+}
+
+// toTimeOfDay converts a clock time to the fraction of a day Excel uses for
+// time values.
+func toTimeOfDay(value string) (float64, error) {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			seconds := t.Hour()*3600 + t.Minute()*60 + t.Second()
+			return float64(seconds) / 86400, nil
+		}
+	}
+	return 0, fmt.Errorf("failed to convert %v to time", value)
+}
+
+var isoDuration = regexp.MustCompile(`^P(?:(\d+(?:\.\d+)?)W)?(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// toDuration converts an ISO-8601 duration such as PT1H30M, or a number of
+// seconds, to the fraction of days Excel uses for [h]:mm:ss values. Years
+// and months have no fixed length and are not accepted.
+func toDuration(value interface{}) (interface{}, error) {
+	var seconds float64
+	switch v := value.(type) {
+	case float64:
+		seconds = v
+	case string:
+		if v == "" {
+			return "", nil
+		}
+		if number, err := strconv.ParseFloat(v, 64); err == nil {
+			seconds = number
+			break
+		}
+
+		match := isoDuration.FindStringSubmatch(v)
+		if match == nil || v == "P" || strings.HasSuffix(v, "T") {
+			return nil, fmt.Errorf("failed to convert %v to duration", v)
+		}
+		units := []float64{7 * 86400, 86400, 3600, 60, 1}
+		for i, unit := range units {
+			if match[i+1] == "" {
+				continue
+			}
+			number, err := strconv.ParseFloat(match[i+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert %v to duration: %w", v, err)
+			}
+			seconds += number * unit
+		}
+	default:
+		return value, nil
+	}
+
+	if math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return nil, fmt.Errorf("failed to convert %v to duration", value)
+	}
+	return seconds / 86400, nil
+}
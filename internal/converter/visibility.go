@@ -38,7 +38,7 @@ func setColumnVisibility(f *excelize.File, sheetName string, meta []types.Column
 	}
 
 	for colIndex, col := range meta {
-		if col.DefaultVisibility == "hidden" || col.DefaultVisibility == "always_hidden" {
+		if isHidden(col) {
 			colName := colIndexToName(colIndex)
 			if err := f.SetColVisible(sheetName, colName, false); err != nil {
 				return err
@@ -52,3 +52,7 @@ func setColumnVisibility(f *excelize.File, sheetName string, meta []types.Column
 
 	return nil
 }
+
+func isHidden(col types.ColumnMeta) bool {
+	return col.DefaultVisibility == "hidden" || col.DefaultVisibility == "always_hidden"
+}
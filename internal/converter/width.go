@@ -10,6 +10,20 @@ import (
 )
 
 func adjustColumnWidths(f *excelize.File, sheetName string, jsonData []map[string]interface{}, meta []types.ColumnMeta) error {
+	// Apply column widths to the Excel sheet
+	for colIndex, width := range computeColumnWidths(jsonData, meta) {
+		colStr := colIndexToName(colIndex)
+		if err := f.SetColWidth(sheetName, colStr, colStr, width); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// computeColumnWidths returns the width of every column in meta, sized to
+// the longest value found in jsonData.
+func computeColumnWidths(jsonData []map[string]interface{}, meta []types.ColumnMeta) []float64 {
 	numCores := runtime.NumCPU()
 	batchSize := (len(jsonData) + numCores - 1) / numCores
 
@@ -37,7 +51,7 @@ func adjustColumnWidths(f *excelize.File, sheetName string, jsonData []map[strin
 
 			for _, row := range batch {
 				for _, col := range meta {
-					cellValue, exists := row[col.Name]
+					cellValue, exists := lookupValue(row, col.Name)
 					if !exists {
 						continue
 					}
@@ -74,16 +88,10 @@ func adjustColumnWidths(f *excelize.File, sheetName string, jsonData []map[strin
 		}
 	}
 
-	// Apply column widths to the Excel sheet
-	for colName, width := range globalColWidths {
-		colIndex := getColumnIndex(meta, colName)
-		if colIndex != -1 {
-			colStr := colIndexToName(colIndex)
-			if err := f.SetColWidth(sheetName, colStr, colStr, width); err != nil {
-				return err
-			}
-		}
+	widths := make([]float64, len(meta))
+	for colIndex, col := range meta {
+		widths[colIndex] = globalColWidths[col.Name]
 	}
 
-	return nil
+	return widths
 }
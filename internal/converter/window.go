@@ -0,0 +1,123 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jagac/excelify/internal/types"
+	"github.com/xuri/excelize/v2"
+)
+
+// headerScanRows is the number of rows inspected when detecting the header.
+const headerScanRows = 50
+
+// sheetWindow bounds the part of a sheet read by ConvertToJson. Indexes are
+// zero-based and a negative last index means there is no limit.
+type sheetWindow struct {
+	headerRow int
+	lastRow   int
+	firstCol  int
+	lastCol   int
+}
+
+func resolveWindow(rows [][]string, opts types.JsonOptions) (sheetWindow, error) {
+	window := sheetWindow{lastRow: -1, lastCol: -1}
+
+	if opts.Range != "" {
+		firstCol, firstRow, lastCol, lastRow, err := parseRange(opts.Range)
+		if err != nil {
+			return window, err
+		}
+		window = sheetWindow{headerRow: firstRow, lastRow: lastRow, firstCol: firstCol, lastCol: lastCol}
+	}
+
+	if opts.HeaderRow > 0 {
+		headerRow := opts.HeaderRow - 1
+		if headerRow < window.headerRow || (window.lastRow >= 0 && headerRow > window.lastRow) {
+			return window, fmt.Errorf("%w: header row %d is outside of range %s", types.ErrInvalidOptions, opts.HeaderRow, opts.Range)
+		}
+		window.headerRow = headerRow
+	}
+
+	if opts.DetectHeader {
+		window.detectHeader(rows)
+	}
+
+	return window, nil
+}
+
+// parseRange parses an A1-style range such as B4:K200 into zero-based
+// coordinates.
+func parseRange(ref string) (int, int, int, int, error) {
+	first, last, ok := strings.Cut(ref, ":")
+	if !ok {
+		return 0, 0, 0, 0, fmt.Errorf("%w: range %q is not of the form A1:B2", types.ErrInvalidOptions, ref)
+	}
+
+	firstCol, firstRow, err := excelize.CellNameToCoordinates(first)
+	if err != nil {
+		return 0, 0, 0, 0, fmt.Errorf("%w: %v", types.ErrInvalidOptions, err)
+	}
+	lastCol, lastRow, err := excelize.CellNameToCoordinates(last)
+	if err != nil {
+		return 0, 0, 0, 0, fmt.Errorf("%w: %v", types.ErrInvalidOptions, err)
+	}
+
+	return min(firstCol, lastCol) - 1, min(firstRow, lastRow) - 1, max(firstCol, lastCol) - 1, max(firstRow, lastRow) - 1, nil
+}
+
+// detectHeader moves the header to the first dense row, skipping title rows,
+// logos and notes above the table. A row is dense when at least half as many
+// of its cells are filled as in the fullest row near the top of the window.
+// The window then starts at the first filled cell of that row.
+func (w *sheetWindow) detectHeader(rows [][]string) {
+	counts := make([]int, 0, headerScanRows)
+	maxCount := 0
+	for r := w.headerRow; r < len(rows) && len(counts) < headerScanRows && (w.lastRow < 0 || r <= w.lastRow); r++ {
+		count := 0
+		for _, cell := range w.clip(rows[r]) {
+			if strings.TrimSpace(cell) != "" {
+				count++
+			}
+		}
+		counts = append(counts, count)
+		maxCount = max(maxCount, count)
+	}
+
+	threshold := max(1, (maxCount+1)/2)
+	for i, count := range counts {
+		if count < threshold {
+			continue
+		}
+
+		w.headerRow += i
+		for col, cell := range w.clip(rows[w.headerRow]) {
+			if strings.TrimSpace(cell) != "" {
+				w.firstCol += col
+				break
+			}
+		}
+		return
+	}
+}
+
+// clip returns the cells of row that fall inside the window's columns.
+func (w sheetWindow) clip(row []string) []string {
+	if w.firstCol >= len(row) {
+		return nil
+	}
+	end := len(row)
+	if w.lastCol >= 0 && w.lastCol+1 < end {
+		end = w.lastCol + 1
+	}
+	return row[w.firstCol:end]
+}
+
+// dataRows returns the zero-based indexes of the first and last data rows.
+func (w sheetWindow) dataRows(rows [][]string) (int, int) {
+	last := len(rows) - 1
+	if w.lastRow >= 0 && w.lastRow < last {
+		last = w.lastRow
+	}
+	return w.headerRow + 1, last
+}
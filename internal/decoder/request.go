@@ -0,0 +1,277 @@
+package decoder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jagac/excelify/internal/types"
+)
+
+// Request is a decoded to-excel request. Sheets may carry a RowReader that
+// keeps decoding rows from the request body as the converter asks for them.
+// Filename is only final once all rows have been read, since it may follow
+// the data in the body.
+type Request struct {
+	Filename string
+	Sheets   []types.Sheet
+	Options  types.ExcelOptions
+	// Template names the template filled with Values, when set.
+	Template string
+	Values   map[string]interface{}
+}
+
+// DecodeError marks errors caused by a malformed request body, as opposed to
+// errors raised while converting the decoded rows.
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("cannot decode request: %v", e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeJSON reads a JSON request body. When "meta" precedes "data" in the
+// body, the data rows are not decoded up front but streamed one at a time
+// through the returned sheet's RowReader. Otherwise the body is decoded in
+// full, the same way as before.
+func DecodeJSON(r io.Reader) (*Request, error) {
+	s := &jsonState{dec: json.NewDecoder(r), req: &Request{}}
+	if err := s.expectDelim('{'); err != nil {
+		return nil, err
+	}
+
+	streaming, err := s.readFields()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(s.sheets) > 0:
+		s.req.Sheets = s.sheets
+	case streaming:
+		s.req.Sheets = []types.Sheet{{Name: "Sheet1", Meta: s.meta, Rows: &jsonRows{state: s}}}
+	default:
+		s.req.Sheets = []types.Sheet{{Name: "Sheet1", Data: s.data, Meta: s.meta, Keys: s.keys.keys}}
+	}
+
+	return s.req, nil
+}
+
+// DecodeNDJSON reads a body of newline delimited JSON objects, one row per
+// line. Column meta and options cannot be part of such a body, so they are
+// passed in.
+func DecodeNDJSON(r io.Reader, filename string, meta types.MetaData, opts types.ExcelOptions) (*Request, error) {
+	rows := &ndjsonRows{dec: json.NewDecoder(r)}
+	sheet := types.Sheet{Name: "Sheet1", Meta: meta}
+
+	first, err := rows.Next()
+	switch {
+	case errors.Is(err, io.EOF):
+	case err != nil:
+		return nil, err
+	default:
+		rows.pending = first
+		sheet.Rows = rows
+	}
+
+	return &Request{Filename: filename, Sheets: []types.Sheet{sheet}, Options: opts}, nil
+}
+
+// beforeData are the fields that change how rows are converted or written,
+// or which sheets and template they are written to.
+var beforeData = map[string]bool{
+	"mode":            true,
+	"invalid_values":  true,
+	"explode":         true,
+	"array_delimiter": true,
+	"format":          true,
+	"csv":             true,
+	"sheets":          true,
+	"template":        true,
+	"values":          true,
+}
+
+type jsonState struct {
+	dec      *json.Decoder
+	req      *Request
+	meta     types.MetaData
+	metaSeen bool
+	data     []map[string]interface{}
+	keys     keyOrder
+	sheets   []types.Sheet
+	streamed bool
+	// afterRows is set once streamed rows have been handed out, when
+	// options, sheets and templates can no longer take effect.
+	afterRows bool
+}
+
+// readFields reads the fields of the top-level object. It stops early and
+// returns true when it is positioned inside a non-empty "data" array that
+// can be streamed, and consumes the closing brace otherwise. Options,
+// sheets and templates that follow streamed rows are rejected, since the
+// rows were converted without them.
+func (s *jsonState) readFields() (bool, error) {
+	for s.dec.More() {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return false, &DecodeError{Err: err}
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return false, &DecodeError{Err: fmt.Errorf("unexpected token %v", tok)}
+		}
+
+		if s.afterRows && beforeData[key] {
+			return false, &DecodeError{Err: fmt.Errorf("%q must come before data", key)}
+		}
+
+		var target interface{}
+		switch key {
+		case "filename":
+			target = &s.req.Filename
+		case "meta":
+			target = &s.meta
+			s.metaSeen = true
+		case "sheets":
+			if s.sheets, err = decodeSheets(s.dec); err != nil {
+				return false, &DecodeError{Err: err}
+			}
+			continue
+		case "mode":
+			target = &s.req.Options.Mode
+		case "invalid_values":
+			target = &s.req.Options.InvalidValues
+		case "explode":
+			target = &s.req.Options.Explode
+		case "array_delimiter":
+			target = &s.req.Options.ArrayDelimiter
+		case "format":
+			target = &s.req.Options.Format
+		case "csv":
+			target = &s.req.Options.CSV
+		case "template":
+			target = &s.req.Template
+		case "values":
+			target = &s.req.Values
+		case "data":
+			if s.metaSeen && !s.streamed && len(s.sheets) == 0 {
+				streaming, err := s.openData()
+				if err != nil || streaming {
+					return streaming, err
+				}
+				continue
+			}
+			if s.data, err = decodeRows(s.dec, &s.keys); err != nil {
+				return false, &DecodeError{Err: err}
+			}
+			continue
+		default:
+			target = &json.RawMessage{}
+		}
+
+		if err := s.dec.Decode(target); err != nil {
+			return false, &DecodeError{Err: err}
+		}
+	}
+
+	return false, s.expectDelim('}')
+}
+
+// openData positions the decoder on the first element of the "data" array.
+// Empty and null arrays are consumed right away.
+func (s *jsonState) openData() (bool, error) {
+	tok, err := s.dec.Token()
+	if err != nil {
+		return false, &DecodeError{Err: err}
+	}
+	if tok == nil {
+		return false, nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return false, &DecodeError{Err: fmt.Errorf("expected data to be an array, got %v", tok)}
+	}
+
+	s.streamed = true
+	if s.dec.More() {
+		return true, nil
+	}
+	return false, s.expectDelim(']')
+}
+
+func (s *jsonState) expectDelim(want json.Delim) error {
+	if err := expectDelim(s.dec, want); err != nil {
+		return &DecodeError{Err: err}
+	}
+	return nil
+}
+
+// jsonRows streams the elements of the "data" array. After the last row it
+// reads whatever fields follow the array, so the request is complete by the
+// time io.EOF is returned.
+type jsonRows struct {
+	state *jsonState
+	done  bool
+}
+
+func (r *jsonRows) Next() (map[string]interface{}, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+
+	if r.state.dec.More() {
+		row, err := decodeRow(r.state.dec, &r.state.keys)
+		if err != nil {
+			return nil, &DecodeError{Err: err}
+		}
+		return row, nil
+	}
+
+	r.done = true
+	if err := r.state.expectDelim(']'); err != nil {
+		return nil, err
+	}
+	r.state.afterRows = true
+	if _, err := r.state.readFields(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Keys returns the keys of the rows read so far in first-seen order.
+func (r *jsonRows) Keys() []string {
+	return r.state.keys.keys
+}
+
+type ndjsonRows struct {
+	dec     *json.Decoder
+	keys    keyOrder
+	pending map[string]interface{}
+}
+
+func (r *ndjsonRows) Next() (map[string]interface{}, error) {
+	if r.pending != nil {
+		row := r.pending
+		r.pending = nil
+		return row, nil
+	}
+
+	row, err := decodeRow(r.dec, &r.keys)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, &DecodeError{Err: err}
+	}
+	return row, nil
+}
+
+// Keys returns the keys of the rows read so far in first-seen order.
+func (r *ndjsonRows) Keys() []string {
+	return r.keys.keys
+}
@@ -0,0 +1,147 @@
+package decoder
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jagac/excelify/internal/types"
+)
+
+// keyOrder records row keys in the order they first appear, which decoding
+// into maps would otherwise lose.
+type keyOrder struct {
+	keys []string
+	seen map[string]bool
+}
+
+func (k *keyOrder) add(key string) {
+	if k.seen == nil {
+		k.seen = make(map[string]bool)
+	}
+	if !k.seen[key] {
+		k.seen[key] = true
+		k.keys = append(k.keys, key)
+	}
+}
+
+// decodeRow decodes one row object, adding its keys to order. A null row
+// decodes to a nil map.
+func decodeRow(dec *json.Decoder, order *keyOrder) (map[string]interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok == nil {
+		return nil, nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("expected row to be an object, got %v", tok)
+	}
+
+	row := make(map[string]interface{})
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected token %v", tok)
+		}
+
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		row[key] = value
+		order.add(key)
+	}
+
+	return row, expectDelim(dec, '}')
+}
+
+// decodeRows decodes an array of row objects.
+func decodeRows(dec *json.Decoder, order *keyOrder) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	err := decodeArray(dec, func() error {
+		row, err := decodeRow(dec, order)
+		rows = append(rows, row)
+		return err
+	})
+	return rows, err
+}
+
+// decodeSheets decodes the "sheets" array of a multi-sheet request.
+func decodeSheets(dec *json.Decoder) ([]types.Sheet, error) {
+	var sheets []types.Sheet
+	err := decodeArray(dec, func() error {
+		if err := expectDelim(dec, '{'); err != nil {
+			return err
+		}
+
+		var sheet types.Sheet
+		var order keyOrder
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, ok := tok.(string)
+			if !ok {
+				return fmt.Errorf("unexpected token %v", tok)
+			}
+
+			switch key {
+			case "name":
+				err = dec.Decode(&sheet.Name)
+			case "meta":
+				err = dec.Decode(&sheet.Meta)
+			case "data":
+				sheet.Data, err = decodeRows(dec, &order)
+			default:
+				err = dec.Decode(&json.RawMessage{})
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		sheet.Keys = order.keys
+		sheets = append(sheets, sheet)
+		return expectDelim(dec, '}')
+	})
+	return sheets, err
+}
+
+// decodeArray calls decodeElement for every element of an array. A null
+// array has no elements.
+func decodeArray(dec *json.Decoder, decodeElement func() error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected an array, got %v", tok)
+	}
+
+	for dec.More() {
+		if err := decodeElement(); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("expected %v, got %v", want, tok)
+	}
+	return nil
+}
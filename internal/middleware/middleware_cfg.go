@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"bytes"
 	"log/slog"
 	"net/http"
 	"time"
@@ -45,7 +44,7 @@ func (l *LoggingConfig) Middleware(next http.Handler) http.Handler {
 			slog.String("remote_addr", r.RemoteAddr),
 			slog.Time("start_time", start))
 
-		recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK, body: &bytes.Buffer{}}
+		recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
 
 		next.ServeHTTP(recorder, r)
 
@@ -75,7 +74,6 @@ type statusRecorder struct {
 	http.ResponseWriter
 	statusCode int
 	err        error
-	body       *bytes.Buffer
 }
 
 func (rec *statusRecorder) WriteHeader(code int) {
@@ -88,6 +86,5 @@ func (rec *statusRecorder) Write(b []byte) (int, error) {
 	if err != nil {
 		rec.err = err
 	}
-	rec.body.Write(b)
 	return n, err
 }
@@ -2,50 +2,133 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/jagac/excelify/internal/decoder"
 	"github.com/jagac/excelify/internal/types"
 	"github.com/xuri/excelize/v2"
 )
 
 type Handler struct {
 	converter types.Converter
+	templates types.TemplateStore
+	registry  types.TemplateRegistry
 }
 
-func NewHandler(converter types.Converter) *Handler {
+// HandlerOption configures optional features of a Handler.
+type HandlerOption func(*Handler)
 
-	return &Handler{
+// WithTemplates lets to-excel requests fill the templates of store by name.
+func WithTemplates(store types.TemplateStore) HandlerOption {
+	return func(h *Handler) {
+		h.templates = store
+	}
+}
+
+// WithTemplateRegistry serves the template endpoints from registry, whose
+// templates to-excel requests can then fill by reference.
+func WithTemplateRegistry(registry types.TemplateRegistry) HandlerOption {
+	return func(h *Handler) {
+		h.templates = registry
+		h.registry = registry
+	}
+}
+
+func NewHandler(converter types.Converter, opts ...HandlerOption) *Handler {
+
+	h := &Handler{
 		converter: converter,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 func (h *Handler) HandleJsonToExcel(w http.ResponseWriter, r *http.Request) {
 
-	var jsonData types.RequestJson
-	if err := json.NewDecoder(r.Body).Decode(&jsonData); err != nil {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		h.handleTemplateUpload(w, r)
+		return
+	}
+
+	request, err := decodeExcelRequest(r)
+	if err != nil {
 		http.Error(w, "Cannot decode JSON", http.StatusBadRequest)
 		return
 	}
 
-	if len(jsonData.Data) == 0 {
-		http.Error(w, "No data provided", http.StatusBadRequest)
+	switch request.Options.Mode {
+	case "", types.ModeStrict, types.ModeLenient:
+	default:
+		http.Error(w, "Invalid value for mode", http.StatusBadRequest)
+		return
+	}
+	switch request.Options.InvalidValues {
+	case "", types.InvalidValuesText, types.InvalidValuesBlank:
+	default:
+		http.Error(w, "Invalid value for invalid_values", http.StatusBadRequest)
 		return
 	}
 
-	excelBuffer, err := h.converter.ConvertToExcel(jsonData.Data, jsonData.Meta.Columns)
-	if err != nil {
+	if request.Template != "" {
+		if format := strings.ToLower(request.Options.Format); format != "" && format != types.FormatXLSX {
+			http.Error(w, "Templates can only be filled as xlsx", http.StatusBadRequest)
+			return
+		}
+		template, ok := h.openTemplate(w, request.Template)
+		if !ok {
+			return
+		}
+		defer template.Close()
+		h.fillTemplate(w, template, request.Filename, request.Values, request.Options)
+		return
+	}
 
-		http.Error(w, "Failed to convert to Excel", http.StatusInternalServerError)
+	if !hasData(request.Sheets) {
+		http.Error(w, "No data provided", http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Disposition", "attachment; filename="+jsonData.Filename)
-	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet; charset=utf-8")
+	format := request.Options.Format
+	if format == "" {
+		format = negotiateFormat(r.Header.Get("Accept"))
+	}
+	encoder, err := h.converter.Encoder(format)
+	if err != nil {
+		http.Error(w, "Invalid value for format", http.StatusBadRequest)
+		return
+	}
 
-	w.WriteHeader(http.StatusOK)
+	// Headers are set on the first write, because with streamed decoding the
+	// filename is only known once every row has been read.
+	body := newDownloadBody(w, encoder.ContentType(), func() string { return request.Filename })
+	if err := encoder.Encode(body, request.Sheets, request.Options); err != nil {
+		// Once the workbook started streaming the status can no longer change.
+		if body.written {
+			return
+		}
 
-	if _, err := w.Write(excelBuffer.Bytes()); err != nil {
-		http.Error(w, "Failed to write Excel response", http.StatusInternalServerError)
+		var decodeErr *decoder.DecodeError
+		if errors.As(err, &decodeErr) {
+			http.Error(w, "Cannot decode JSON", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, types.ErrInvalidMeta) || errors.Is(err, types.ErrInvalidOptions) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var conversionErr *types.ConversionError
+		if errors.As(err, &conversionErr) {
+			writeJsonError(w, http.StatusUnprocessableEntity, conversionErr)
+			return
+		}
+		http.Error(w, "Failed to convert to Excel", http.StatusInternalServerError)
 		return
 	}
 
@@ -65,7 +148,80 @@ func (h *Handler) HandleExcelToJson(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jsonData, err := h.converter.ConvertToJson(f)
+	var opts types.JsonOptions
+	if typed := r.FormValue("typed"); typed != "" {
+		if opts.Typed, err = strconv.ParseBool(typed); err != nil {
+			http.Error(w, "Invalid value for typed", http.StatusBadRequest)
+			return
+		}
+	}
+
+	opts.Sheet = r.FormValue("sheet")
+	if allSheets := r.FormValue("all_sheets"); allSheets != "" {
+		if opts.AllSheets, err = strconv.ParseBool(allSheets); err != nil {
+			http.Error(w, "Invalid value for all_sheets", http.StatusBadRequest)
+			return
+		}
+	}
+
+	switch opts.ExtraCells = r.FormValue("extra_cells"); opts.ExtraCells {
+	case "", types.ExtraCellsSynthesize, types.ExtraCellsDrop, types.ExtraCellsError:
+	default:
+		http.Error(w, "Invalid value for extra_cells", http.StatusBadRequest)
+		return
+	}
+
+	switch headerRow := r.FormValue("header_row"); headerRow {
+	case "":
+	case "auto":
+		opts.DetectHeader = true
+	default:
+		if opts.HeaderRow, err = strconv.Atoi(headerRow); err != nil || opts.HeaderRow < 1 {
+			http.Error(w, "Invalid value for header_row", http.StatusBadRequest)
+			return
+		}
+	}
+	opts.Range = r.FormValue("range")
+	if keys := r.FormValue("keys"); keys != "" {
+		if err := json.Unmarshal([]byte(keys), &opts.HeaderKeys); err != nil {
+			http.Error(w, "Invalid value for keys", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if unflatten := r.FormValue("unflatten"); unflatten != "" {
+		if opts.Unflatten, err = strconv.ParseBool(unflatten); err != nil {
+			http.Error(w, "Invalid value for unflatten", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if meta := r.FormValue("meta"); meta != "" {
+		if err := json.Unmarshal([]byte(meta), &opts.Meta); err != nil {
+			http.Error(w, "Invalid value for meta", http.StatusBadRequest)
+			return
+		}
+	}
+
+	jsonData, err := h.converter.ConvertToJson(f, opts)
+	if errors.Is(err, types.ErrSheetNotFound) {
+		http.Error(w, "Sheet not found", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, types.ErrInvalidOptions) || errors.Is(err, types.ErrInvalidMeta) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var validationErr *types.ValidationError
+	if errors.As(err, &validationErr) {
+		writeJsonError(w, http.StatusUnprocessableEntity, validationErr)
+		return
+	}
+	var sheetErr *types.SheetError
+	if errors.As(err, &sheetErr) {
+		writeJsonError(w, http.StatusUnprocessableEntity, sheetErr)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to convert Excel to JSON", http.StatusInternalServerError)
 		return
@@ -78,3 +234,302 @@ func (h *Handler) HandleExcelToJson(w http.ResponseWriter, r *http.Request) {
 	}
 
 }
+
+// handleTemplateUpload fills a template sent as the "template" file of a
+// multipart form, or named by the "template" field, with the JSON object in
+// the "values" field.
+func (h *Handler) handleTemplateUpload(w http.ResponseWriter, r *http.Request) {
+	var template io.Reader
+	file, _, err := r.FormFile("template")
+	switch {
+	case err == nil:
+		defer file.Close()
+		template = file
+	case r.FormValue("template") != "":
+		stored, ok := h.openTemplate(w, r.FormValue("template"))
+		if !ok {
+			return
+		}
+		defer stored.Close()
+		template = stored
+	default:
+		http.Error(w, "Failed to read template from request", http.StatusBadRequest)
+		return
+	}
+
+	var values map[string]interface{}
+	if raw := r.FormValue("values"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &values); err != nil {
+			http.Error(w, "Invalid value for values", http.StatusBadRequest)
+			return
+		}
+	}
+
+	opts := types.ExcelOptions{ArrayDelimiter: r.FormValue("array_delimiter")}
+	h.fillTemplate(w, template, r.FormValue("filename"), values, opts)
+}
+
+// openTemplate opens a template by name, responding with an error and
+// returning false when it cannot.
+func (h *Handler) openTemplate(w http.ResponseWriter, name string) (io.ReadCloser, bool) {
+	if h.templates == nil {
+		http.Error(w, "Template not found", http.StatusBadRequest)
+		return nil, false
+	}
+	template, err := h.templates.Open(name)
+	if errors.Is(err, types.ErrTemplateNotFound) {
+		http.Error(w, "Template not found", http.StatusBadRequest)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to read template", http.StatusInternalServerError)
+		return nil, false
+	}
+	return template, true
+}
+
+func (h *Handler) fillTemplate(w http.ResponseWriter, template io.Reader, filename string, values map[string]interface{}, opts types.ExcelOptions) {
+	body := newExcelBody(w, func() string { return filename })
+	if err := h.converter.FillTemplate(body, template, values, opts); err != nil {
+		if body.written {
+			return
+		}
+		if errors.Is(err, types.ErrInvalidTemplate) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to fill template", http.StatusInternalServerError)
+	}
+}
+
+// HandleUploadTemplate stores the "template" file of a multipart form as
+// the next version of the template named in the path.
+func (h *Handler) HandleUploadTemplate(w http.ResponseWriter, r *http.Request) {
+	if !h.hasRegistry(w) {
+		return
+	}
+	file, _, err := r.FormFile("template")
+	if err != nil {
+		http.Error(w, "Failed to read template from request", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	f, err := excelize.OpenReader(file)
+	if err != nil {
+		http.Error(w, "Failed to parse Excel file", http.StatusBadRequest)
+		return
+	}
+	f.Close()
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, "Failed to save template", http.StatusInternalServerError)
+		return
+	}
+
+	version, err := h.registry.Save(r.PathValue("name"), file)
+	if errors.Is(err, types.ErrInvalidTemplate) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to save template", http.StatusInternalServerError)
+		return
+	}
+	writeJson(w, http.StatusCreated, version)
+}
+
+// HandleListTemplates lists the stored templates with their versions.
+func (h *Handler) HandleListTemplates(w http.ResponseWriter, r *http.Request) {
+	if !h.hasRegistry(w) {
+		return
+	}
+	infos, err := h.registry.List()
+	if err != nil {
+		http.Error(w, "Failed to list templates", http.StatusInternalServerError)
+		return
+	}
+	writeJson(w, http.StatusOK, infos)
+}
+
+// HandleGetTemplate downloads the template referenced in the path, such as
+// "invoice@3", or "invoice" for its latest version.
+func (h *Handler) HandleGetTemplate(w http.ResponseWriter, r *http.Request) {
+	if !h.hasRegistry(w) {
+		return
+	}
+	ref := r.PathValue("ref")
+	template, err := h.registry.Open(ref)
+	if errors.Is(err, types.ErrTemplateNotFound) {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to read template", http.StatusInternalServerError)
+		return
+	}
+	defer template.Close()
+
+	name, _, _ := strings.Cut(ref, "@")
+	body := newExcelBody(w, func() string { return name + ".xlsx" })
+	if _, err := io.Copy(body, template); err != nil && !body.written {
+		http.Error(w, "Failed to read template", http.StatusInternalServerError)
+	}
+}
+
+// HandleDeleteTemplate deletes the template version referenced in the path,
+// or every version of the template when the reference has none.
+func (h *Handler) HandleDeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	if !h.hasRegistry(w) {
+		return
+	}
+	err := h.registry.Delete(r.PathValue("ref"))
+	if errors.Is(err, types.ErrTemplateNotFound) {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete template", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// hasRegistry responds with an error and returns false when the handler has
+// no template registry.
+func (h *Handler) hasRegistry(w http.ResponseWriter) bool {
+	if h.registry == nil {
+		http.Error(w, "Template registry is not enabled", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// writeJson responds with body encoded as JSON.
+func writeJson(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// writeJsonError responds with a JSON body describing what went wrong.
+func writeJsonError(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": body})
+}
+
+// decodeExcelRequest decodes a to-excel request body. NDJSON bodies carry
+// one row per line, so their meta and filename come from headers or query
+// parameters instead. Without meta the columns are inferred from the rows.
+func decodeExcelRequest(r *http.Request) (*decoder.Request, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/x-ndjson" && mediaType != "application/jsonl" {
+		return decoder.DecodeJSON(r.Body)
+	}
+
+	rawMeta := r.Header.Get("X-Excelify-Meta")
+	if rawMeta == "" {
+		rawMeta = r.URL.Query().Get("meta")
+	}
+	var meta types.MetaData
+	if rawMeta != "" {
+		if err := json.Unmarshal([]byte(rawMeta), &meta); err != nil {
+			return nil, err
+		}
+	}
+
+	filename := r.Header.Get("X-Excelify-Filename")
+	if filename == "" {
+		filename = r.URL.Query().Get("filename")
+	}
+
+	opts := types.ExcelOptions{
+		Mode:           r.URL.Query().Get("mode"),
+		InvalidValues:  r.URL.Query().Get("invalid_values"),
+		Explode:        r.URL.Query().Get("explode"),
+		ArrayDelimiter: r.URL.Query().Get("array_delimiter"),
+		Format:         r.URL.Query().Get("format"),
+	}
+	if rawCSV := r.URL.Query().Get("csv"); rawCSV != "" {
+		if err := json.Unmarshal([]byte(rawCSV), &opts.CSV); err != nil {
+			return nil, err
+		}
+	}
+
+	return decoder.DecodeNDJSON(r.Body, filename, meta, opts)
+}
+
+func hasData(sheets []types.Sheet) bool {
+	for _, sheet := range sheets {
+		if len(sheet.Data) > 0 || sheet.Rows != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// newExcelBody returns a writer that sets the headers of an XLSX download
+// right before the first write.
+func newExcelBody(w http.ResponseWriter, filename func() string) *bodyWriter {
+	return newDownloadBody(w, types.ContentTypeXLSX, filename)
+}
+
+// newDownloadBody returns a writer that sets the headers of a download of
+// contentType right before the first write.
+func newDownloadBody(w http.ResponseWriter, contentType string, filename func() string) *bodyWriter {
+	return &bodyWriter{ResponseWriter: w, beforeWrite: func() {
+		w.Header().Set("Content-Disposition", "attachment; filename="+filename())
+		w.Header().Set("Content-Type", contentType)
+	}}
+}
+
+// acceptedFormats maps the media types of an Accept header to output
+// formats.
+var acceptedFormats = map[string]string{
+	"text/csv":                  types.FormatCSV,
+	"text/tab-separated-values": types.FormatTSV,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": types.FormatXLSX,
+}
+
+// negotiateFormat returns the output format an Accept header prefers,
+// taking the one with the highest q-value and the first listed among equals.
+// Media ranges with a q-value of 0 are not acceptable. FormatXLSX is returned
+// when the header lists no format.
+func negotiateFormat(accept string) string {
+	format, best := types.FormatXLSX, 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if accepted, ok := acceptedFormats[mediaType]; ok && q > best {
+			format, best = accepted, q
+		}
+	}
+	return format
+}
+
+// bodyWriter records whether any part of the response body has been sent
+// and runs beforeWrite right before the first write.
+type bodyWriter struct {
+	http.ResponseWriter
+	beforeWrite func()
+	written     bool
+}
+
+func (b *bodyWriter) Write(p []byte) (int, error) {
+	if !b.written {
+		b.written = true
+		if b.beforeWrite != nil {
+			b.beforeWrite()
+		}
+	}
+	return b.ResponseWriter.Write(p)
+}
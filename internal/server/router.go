@@ -36,5 +36,9 @@ func (r *Router) RegisterRoutes(mux *http.ServeMux) {
 	mux.Handle("/metrics", promhttp.Handler())
 	mux.Handle("POST /api/v1/conversions/to-excel", middleware.MetricsMiddleware("POST /api/v1/conversions/to-excel", r.corsMiddleware(r.logMiddleware(http.HandlerFunc(r.handler.HandleJsonToExcel)))))
 	mux.Handle("POST /api/v1/conversions/to-json", middleware.MetricsMiddleware("POST /api/v1/conversions/to-json", r.corsMiddleware(r.logMiddleware(http.HandlerFunc(r.handler.HandleExcelToJson)))))
+	mux.Handle("GET /api/v1/templates", middleware.MetricsMiddleware("GET /api/v1/templates", r.corsMiddleware(r.logMiddleware(http.HandlerFunc(r.handler.HandleListTemplates)))))
+	mux.Handle("POST /api/v1/templates/{name}", middleware.MetricsMiddleware("POST /api/v1/templates/{name}", r.corsMiddleware(r.logMiddleware(http.HandlerFunc(r.handler.HandleUploadTemplate)))))
+	mux.Handle("GET /api/v1/templates/{ref}", middleware.MetricsMiddleware("GET /api/v1/templates/{ref}", r.corsMiddleware(r.logMiddleware(http.HandlerFunc(r.handler.HandleGetTemplate)))))
+	mux.Handle("DELETE /api/v1/templates/{ref}", middleware.MetricsMiddleware("DELETE /api/v1/templates/{ref}", r.corsMiddleware(r.logMiddleware(http.HandlerFunc(r.handler.HandleDeleteTemplate)))))
 
 }
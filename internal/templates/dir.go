@@ -0,0 +1,32 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/jagac/excelify/internal/types"
+)
+
+// templateName restricts template names to plain file names, so that a
+// name cannot reach outside the template directory.
+var templateName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Dir serves the templates stored as <name>.xlsx files in a directory.
+type Dir string
+
+func (d Dir) Open(name string) (io.ReadCloser, error) {
+	if !templateName.MatchString(name) {
+		return nil, fmt.Errorf("%w: invalid template name %q", types.ErrTemplateNotFound, name)
+	}
+
+	file, err := os.Open(filepath.Join(string(d), name+".xlsx"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %q", types.ErrTemplateNotFound, name)
+	}
+	return file, err
+}
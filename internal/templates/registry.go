@@ -0,0 +1,242 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jagac/excelify/internal/types"
+)
+
+// latestFile records the highest version a template has been given, so that
+// the number of a deleted version is not handed out again.
+const latestFile = "latest"
+
+// Registry stores the versions of a template as <name>/<version>.xlsx files
+// in a directory. Templates stored as plain <name>.xlsx files, as served by
+// Dir, are still opened by name when they have no uploaded versions.
+type Registry struct {
+	dir string
+	// mu keeps concurrent uploads from taking the same version number.
+	mu sync.Mutex
+}
+
+func NewRegistry(dir string) *Registry {
+	return &Registry{dir: dir}
+}
+
+func (r *Registry) Open(ref string) (io.ReadCloser, error) {
+	name, version, err := parseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	if version == 0 {
+		versions, err := r.versions(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) == 0 {
+			return Dir(r.dir).Open(name)
+		}
+		version = versions[len(versions)-1]
+	}
+
+	file, err := os.Open(r.path(name, version))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %q", types.ErrTemplateNotFound, ref)
+	}
+	return file, err
+}
+
+func (r *Registry) Save(name string, template io.Reader) (types.TemplateVersion, error) {
+	if !templateName.MatchString(name) {
+		return types.TemplateVersion{}, fmt.Errorf("%w: invalid template name %q", types.ErrInvalidTemplate, name)
+	}
+	dir := filepath.Join(r.dir, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return types.TemplateVersion{}, err
+	}
+
+	// The upload is written to a temporary file first, so that a version is
+	// never seen half written.
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return types.TemplateVersion{}, err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, template)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return types.TemplateVersion{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	version, err := r.latest(name)
+	if err != nil {
+		return types.TemplateVersion{}, err
+	}
+	version++
+	if err := os.Rename(tmp.Name(), r.path(name, version)); err != nil {
+		return types.TemplateVersion{}, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, latestFile), []byte(strconv.Itoa(version)), 0o644); err != nil {
+		return types.TemplateVersion{}, err
+	}
+	return r.stat(name, version)
+}
+
+func (r *Registry) List() ([]types.TemplateInfo, error) {
+	entries, err := os.ReadDir(r.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []types.TemplateInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	infos := []types.TemplateInfo{}
+	for _, entry := range entries {
+		if !entry.IsDir() || !templateName.MatchString(entry.Name()) {
+			continue
+		}
+		versions, err := r.versions(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) == 0 {
+			continue
+		}
+
+		info := types.TemplateInfo{Name: entry.Name(), Latest: versions[len(versions)-1]}
+		for _, version := range versions {
+			stored, err := r.stat(entry.Name(), version)
+			if errors.Is(err, types.ErrTemplateNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			info.Versions = append(info.Versions, stored)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (r *Registry) Delete(ref string) error {
+	name, version, err := parseRef(ref)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if version != 0 {
+		err := os.Remove(r.path(name, version))
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: %q", types.ErrTemplateNotFound, ref)
+		}
+		return err
+	}
+
+	versions, err := r.versions(name)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return fmt.Errorf("%w: %q", types.ErrTemplateNotFound, ref)
+	}
+	// The latest file is kept, so that a template uploaded again under the
+	// same name does not reuse the numbers of the deleted versions.
+	for _, version := range versions {
+		if err := os.Remove(r.path(name, version)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Registry) path(name string, version int) string {
+	return filepath.Join(r.dir, name, strconv.Itoa(version)+".xlsx")
+}
+
+// versions returns the stored versions of a template in ascending order.
+func (r *Registry) versions(name string) ([]int, error) {
+	entries, err := os.ReadDir(filepath.Join(r.dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []int
+	for _, entry := range entries {
+		number, ok := strings.CutSuffix(entry.Name(), ".xlsx")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if version, err := strconv.Atoi(number); err == nil && version > 0 {
+			versions = append(versions, version)
+		}
+	}
+	slices.Sort(versions)
+	return versions, nil
+}
+
+// latest returns the highest version a template has been given so far.
+func (r *Registry) latest(name string) (int, error) {
+	latest := 0
+	if content, err := os.ReadFile(filepath.Join(r.dir, name, latestFile)); err == nil {
+		latest, _ = strconv.Atoi(strings.TrimSpace(string(content)))
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+
+	versions, err := r.versions(name)
+	if err != nil {
+		return 0, err
+	}
+	if len(versions) > 0 {
+		latest = max(latest, versions[len(versions)-1])
+	}
+	return latest, nil
+}
+
+func (r *Registry) stat(name string, version int) (types.TemplateVersion, error) {
+	info, err := os.Stat(r.path(name, version))
+	if errors.Is(err, fs.ErrNotExist) {
+		return types.TemplateVersion{}, fmt.Errorf("%w: %q", types.ErrTemplateNotFound, name+"@"+strconv.Itoa(version))
+	}
+	if err != nil {
+		return types.TemplateVersion{}, err
+	}
+	return types.TemplateVersion{Name: name, Version: version, Size: info.Size(), CreatedAt: info.ModTime().UTC()}, nil
+}
+
+// parseRef splits a reference such as "invoice@3" into the template name
+// and version, which is 0 when the reference has none.
+func parseRef(ref string) (string, int, error) {
+	name, number, hasVersion := strings.Cut(ref, "@")
+	if !templateName.MatchString(name) {
+		return "", 0, fmt.Errorf("%w: invalid template name %q", types.ErrTemplateNotFound, name)
+	}
+	if !hasVersion {
+		return name, 0, nil
+	}
+	version, err := strconv.Atoi(number)
+	if err != nil || version < 1 || strconv.Itoa(version) != number {
+		return "", 0, fmt.Errorf("%w: invalid template version %q", types.ErrTemplateNotFound, number)
+	}
+	return name, version, nil
+}
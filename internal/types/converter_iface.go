@@ -2,12 +2,66 @@ package types
 
 import (
 	"bytes"
+	"io"
+	"time"
 
 	"github.com/xuri/excelize/v2"
 )
 
-
 type Converter interface {
-	ConvertToExcel(jsonData []map[string]interface{}, meta []ColumnMeta) (*bytes.Buffer, error)
-	ConvertToJson(f *excelize.File) ([]byte, error)
+	ConvertToExcel(sheets []Sheet, opts ExcelOptions) (*bytes.Buffer, error)
+	StreamToExcel(w io.Writer, sheets []Sheet, opts ExcelOptions) error
+	ConvertToJson(f *excelize.File, opts JsonOptions) ([]byte, error)
+	FillTemplate(w io.Writer, template io.Reader, values map[string]interface{}, opts ExcelOptions) error
+	// Encoder returns the encoder of an output format, or an error wrapping
+	// ErrInvalidOptions when the format is unknown.
+	Encoder(format string) (Encoder, error)
+}
+
+// Encoder writes sheets to w in one output format.
+type Encoder interface {
+	Encode(w io.Writer, sheets []Sheet, opts ExcelOptions) error
+	// ContentType is the media type of the output, such as text/csv.
+	ContentType() string
+}
+
+// TemplateStore looks up XLSX templates by name.
+type TemplateStore interface {
+	// Open returns the contents of the named template, or an error wrapping
+	// ErrTemplateNotFound.
+	Open(name string) (io.ReadCloser, error)
+}
+
+// TemplateRegistry stores named XLSX templates under increasing version
+// numbers. Its Open and Delete take references such as "invoice@3", where
+// "invoice" alone stands for the latest version.
+type TemplateRegistry interface {
+	TemplateStore
+	// Save stores template as the next version of name. An invalid name is
+	// reported with an error wrapping ErrInvalidTemplate.
+	Save(name string, template io.Reader) (TemplateVersion, error)
+	// List returns every stored template with its versions.
+	List() ([]TemplateInfo, error)
+	// Delete removes a version, or every version of a template when the
+	// reference has none, returning an error wrapping ErrTemplateNotFound
+	// when there is nothing to remove.
+	Delete(ref string) error
+}
+
+// TemplateVersion describes a stored version of a template.
+type TemplateVersion struct {
+	Name      string    `json:"name"`
+	Version   int       `json:"version"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
 }
+
+// TemplateInfo describes a template and its versions, oldest first.
+type TemplateInfo struct {
+	Name     string            `json:"name"`
+	Latest   int               `json:"latest"`
+	Versions []TemplateVersion `json:"versions"`
+}
+
+// ContentTypeXLSX is the media type of XLSX workbooks.
+const ContentTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet; charset=utf-8"
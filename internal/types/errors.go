@@ -0,0 +1,83 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrSheetNotFound is returned when a requested sheet is not in the workbook.
+var ErrSheetNotFound = errors.New("sheet not found")
+
+// ErrInvalidOptions is returned when conversion options cannot be applied,
+// such as a malformed cell range.
+var ErrInvalidOptions = errors.New("invalid options")
+
+// ErrInvalidMeta is returned when column meta describes something the
+// converter cannot write, such as an unknown column type.
+var ErrInvalidMeta = errors.New("invalid column meta")
+
+// ErrInvalidTemplate is returned when a template cannot be read or filled.
+var ErrInvalidTemplate = errors.New("invalid template")
+
+// ErrTemplateNotFound is returned when no template has the requested name.
+var ErrTemplateNotFound = errors.New("template not found")
+
+// SheetError reports a problem with the content of a sheet, pointing at the
+// offending cell when there is one.
+type SheetError struct {
+	Sheet  string `json:"sheet"`
+	Cell   string `json:"cell,omitempty"`
+	Reason string `json:"reason"`
+}
+
+func (e *SheetError) Error() string {
+	if e.Cell == "" {
+		return fmt.Sprintf("sheet %q: %s", e.Sheet, e.Reason)
+	}
+	return fmt.Sprintf("sheet %q, cell %s: %s", e.Sheet, e.Cell, e.Reason)
+}
+
+// CellError describes a data value that could not be converted. Row is the
+// zero-based index of the row in the sheet's data.
+type CellError struct {
+	Sheet  string      `json:"sheet"`
+	Row    int         `json:"row"`
+	Cell   string      `json:"cell"`
+	Column string      `json:"column"`
+	Value  interface{} `json:"value"`
+	Reason string      `json:"reason"`
+}
+
+// ConversionError is returned in strict mode when any data value could not
+// be converted. Errors lists the offending cells, up to a limit, and Total
+// counts all of them.
+type ConversionError struct {
+	Errors []CellError `json:"errors"`
+	Total  int         `json:"total"`
+}
+
+func (e *ConversionError) Error() string {
+	return fmt.Sprintf("%d values could not be converted", e.Total)
+}
+
+// ValidationError is returned when imported rows do not match the schema
+// they are checked against. Errors lists the offending cells, up to a limit,
+// and Total counts all of them.
+type ValidationError struct {
+	MissingColumns []MissingColumn `json:"missing_columns,omitempty"`
+	Errors         []CellError     `json:"errors"`
+	Total          int             `json:"total"`
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.MissingColumns) > 0 {
+		return fmt.Sprintf("%d required columns are missing and %d values are invalid", len(e.MissingColumns), e.Total)
+	}
+	return fmt.Sprintf("%d values are invalid", e.Total)
+}
+
+// MissingColumn names a required column not found in a sheet.
+type MissingColumn struct {
+	Sheet  string `json:"sheet"`
+	Column string `json:"column"`
+}
@@ -1,18 +1,293 @@
 package types
 
 type RequestJson struct {
-	Filename string                   `json:"filename"`
-	Data     []map[string]interface{} `json:"data"`
-	Meta     struct {
-		Columns []ColumnMeta `json:"columns"`
-	} `json:"meta"`
+	Filename       string                   `json:"filename"`
+	Data           []map[string]interface{} `json:"data"`
+	Meta           MetaData                 `json:"meta"`
+	Sheets         []Sheet                  `json:"sheets,omitempty"`
+	Mode           string                   `json:"mode,omitempty"`
+	InvalidValues  string                   `json:"invalid_values,omitempty"`
+	Explode        string                   `json:"explode,omitempty"`
+	ArrayDelimiter string                   `json:"array_delimiter,omitempty"`
+	// Template names the XLSX template filled with Values instead of writing
+	// Data to a new workbook.
+	Template string                 `json:"template,omitempty"`
+	Values   map[string]interface{} `json:"values,omitempty"`
+	// Format is the output format, xlsx by default.
+	Format string      `json:"format,omitempty"`
+	CSV    *CSVOptions `json:"csv,omitempty"`
+}
+
+// ExcelOptions controls how ConvertToExcel, StreamToExcel and the output
+// encoders deal with nested values and values that do not match their
+// column type.
+type ExcelOptions struct {
+	// Mode is ModeStrict (the default), which fails the conversion listing
+	// every offending cell, or ModeLenient, which writes them anyway and adds
+	// an Errors sheet describing them.
+	Mode string
+	// InvalidValues decides how offending values are written in lenient
+	// mode: InvalidValuesText (the default) or InvalidValuesBlank.
+	InvalidValues string
+	// Explode is the path of an array, such as "items" or "order.lines",
+	// whose elements are each written to their own row with the values of
+	// the parent row repeated.
+	Explode string
+	// ArrayDelimiter joins the elements of arrays written to a single cell.
+	// Defaults to ", ".
+	ArrayDelimiter string
+	// Format is the output format: FormatXLSX (the default), FormatCSV or
+	// FormatTSV.
+	Format string
+	// CSV controls how FormatCSV and FormatTSV output is written.
+	CSV CSVOptions
+}
+
+// CSVOptions controls the text written by the CSV and TSV encoders.
+type CSVOptions struct {
+	// Delimiter is the single character separating fields. It defaults to
+	// a comma for CSV and a tab for TSV.
+	Delimiter string `json:"delimiter,omitempty"`
+	// Quoting is QuotingMinimal (the default), which quotes fields holding
+	// the delimiter, quotes or line breaks, or QuotingAll.
+	Quoting string `json:"quoting,omitempty"`
+	// BOM starts the output with a UTF-8 byte order mark, which Excel needs
+	// to read the text as UTF-8.
+	BOM bool `json:"bom,omitempty"`
+	// DateFormat, DatetimeFormat and TimeFormat are the Go time layouts of
+	// DATE, DATETIME and TIME values. They default to 2006-01-02,
+	// 2006-01-02 15:04:05 and 15:04:05.
+	DateFormat     string `json:"date_format,omitempty"`
+	DatetimeFormat string `json:"datetime_format,omitempty"`
+	TimeFormat     string `json:"time_format,omitempty"`
+}
+
+const (
+	ModeStrict  = "strict"
+	ModeLenient = "lenient"
+
+	InvalidValuesText  = "text"
+	InvalidValuesBlank = "blank"
+
+	FormatXLSX = "xlsx"
+	FormatCSV  = "csv"
+	FormatTSV  = "tsv"
+
+	QuotingMinimal = "minimal"
+	QuotingAll     = "all"
+)
+
+type Sheet struct {
+	Name string                   `json:"name"`
+	Data []map[string]interface{} `json:"data"`
+	Meta MetaData                 `json:"meta"`
+	// Rows, when set, supplies the data rows instead of Data.
+	Rows RowReader `json:"-"`
+	// Keys lists the keys of Data in the order they first appear, when the
+	// decoder kept track of it.
+	Keys []string `json:"-"`
+}
+
+// RowReader yields data rows one at a time and returns io.EOF after the
+// last one.
+type RowReader interface {
+	Next() (map[string]interface{}, error)
+}
+
+// KeyOrder is implemented by RowReaders that track the order in which keys
+// first appeared in the rows read so far.
+type KeyOrder interface {
+	Keys() []string
 }
 
 type ColumnMeta struct {
-	Name              string `json:"name"`
+	// Name is the key of the value in each data row, or a path into nested
+	// values such as "address.city" or "tags[0]".
+	Name string `json:"name"`
+	// Label is the header text shown in the sheet. Name, the key of the value
+	// in each data row, is shown when it is empty.
+	Label             string `json:"label,omitempty"`
 	Type              string `json:"type"`
 	DefaultVisibility string `json:"default_visibility,omitempty"`
+	// Currency is the ISO 4217 code of CURRENCY columns, such as EUR.
+	Currency string `json:"currency,omitempty"`
+	// Format is an Excel number format such as "#,##0.000" or
+	// "dd.mm.yyyy hh:mm" that replaces the default format of the type.
+	Format string `json:"format,omitempty"`
+	// InputFormats are the Go time layouts accepted by DATE and DATETIME
+	// columns, plus "unix" and "unix_ms" for epoch timestamps.
+	InputFormats []string `json:"input_formats,omitempty"`
+	// Timezone is the IANA time zone DATE and DATETIME values are shown in,
+	// such as Europe/Berlin.
+	Timezone string `json:"timezone,omitempty"`
+	// Formula is the formula template of FORMULA columns, such as
+	// "=C{row}*D{row}", where {row} is replaced by each row's number.
+	Formula string `json:"formula,omitempty"`
+	// Total is the function shown for the column in the totals row below
+	// the data: sum, average, count, count_numbers, min, max, stddev or var.
+	Total string `json:"total,omitempty"`
+	// ConditionalFormats are applied to the data cells of the column in
+	// order.
+	ConditionalFormats []ConditionalFormat `json:"conditional_formats,omitempty"`
+	// Validation constrains the values users can enter in the column, and
+	// the values accepted on import.
+	Validation *Validation `json:"validation,omitempty"`
+	// Required columns must be present on import, with a value in every row.
+	Required bool `json:"required,omitempty"`
+}
+
+// Validation constrains the values of a column. Only one of Enum, a range
+// given by Min and Max, or MaxLength can be set.
+type Validation struct {
+	// Enum lists the allowed values, offered as a dropdown.
+	Enum []string `json:"enum,omitempty"`
+	// Min and Max bound numbers, dates, times and durations. Dates and times
+	// are given like the column's values.
+	Min interface{} `json:"min,omitempty"`
+	Max interface{} `json:"max,omitempty"`
+	// MaxLength limits the number of characters of text.
+	MaxLength int `json:"max_length,omitempty"`
+}
+
+// ConditionalFormat is a conditional formatting rule for the data cells of
+// a column.
+type ConditionalFormat struct {
+	// Type is threshold, duplicates, unique, top, bottom, data_bar,
+	// color_scale or icon_set.
+	Type string `json:"type"`
+	// Criteria compares the cells of threshold rules with Value: >, >=, <,
+	// <=, = or !=. The criteria between and not_between use Min and Max.
+	Criteria string   `json:"criteria,omitempty"`
+	Value    *float64 `json:"value,omitempty"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+	// Percent makes Value of top and bottom rules, 10 by default, a
+	// percentage of the cells instead of a count.
+	Percent bool `json:"percent,omitempty"`
+	// FontColor and FillColor are hex colors such as 9C0006 for the cells
+	// matched by threshold, duplicates, unique, top and bottom rules. Without
+	// either, matched cells get dark red text on a light red fill.
+	FontColor string `json:"font_color,omitempty"`
+	FillColor string `json:"fill_color,omitempty"`
+	// Colors are the two or three colors of a color scale, from the lowest
+	// to the highest value, or the single color of a data bar.
+	Colors []string `json:"colors,omitempty"`
+	// IconStyle is the icon set of icon_set rules, such as 3TrafficLights1.
+	// It defaults to 3Arrows.
+	IconStyle    string `json:"icon_style,omitempty"`
+	ReverseIcons bool   `json:"reverse_icons,omitempty"`
 }
 type MetaData struct {
 	Columns []ColumnMeta `json:"columns"`
+	// Table, when set, writes the sheet as an Excel table instead of adding
+	// an autofilter to the header row.
+	Table *TableOptions `json:"table,omitempty"`
+	// EntryRows is the number of empty rows added below the data for new
+	// entries, formatted and validated like the data rows.
+	EntryRows int `json:"entry_rows,omitempty"`
+	// Charts are drawn from the columns of the sheet.
+	Charts []Chart `json:"charts,omitempty"`
+	// Pivot, when set, adds a pivot table summarizing the sheet.
+	Pivot *PivotTable `json:"pivot,omitempty"`
+}
+
+// PivotTable describes a pivot table over the data rows of a sheet. Fields
+// are given by column name.
+type PivotTable struct {
+	// Rows and Columns are the columns whose values label the rows and
+	// columns of the pivot table.
+	Rows    []string `json:"rows,omitempty"`
+	Columns []string `json:"columns,omitempty"`
+	// Data are the summarized values.
+	Data []PivotValue `json:"data"`
+	// Sheet is the sheet the pivot table is placed on. It defaults to a new
+	// sheet named Pivot, and is added when no sheet has that name.
+	Sheet string `json:"sheet,omitempty"`
+	// Anchor is the cell at the top left corner of the pivot table. It
+	// defaults to A3.
+	Anchor string `json:"anchor,omitempty"`
+	// Style is a built-in pivot table style. It defaults to
+	// PivotStyleLight16.
+	Style string `json:"style,omitempty"`
+}
+
+// PivotValue is a value field of a pivot table.
+type PivotValue struct {
+	Column string `json:"column"`
+	// Function is sum (the default), average, count, count_numbers, min,
+	// max, product, stddev or var.
+	Function string `json:"function,omitempty"`
+	// Label is the caption of the field. It defaults to the function and
+	// the column header, such as "Sum of Amount".
+	Label string `json:"label,omitempty"`
 }
+
+// Chart describes a chart drawn from the data rows of a sheet.
+type Chart struct {
+	// Type is line, bar, column, area, pie or scatter. Bars are horizontal
+	// and columns vertical.
+	Type  string `json:"type"`
+	Title string `json:"title,omitempty"`
+	// Category is the column whose values label the points, or give the x
+	// values of a scatter chart.
+	Category string `json:"category,omitempty"`
+	// Series are the columns plotted, one series each. Pie charts take a
+	// single series.
+	Series []string `json:"series"`
+	// Sheet is the sheet the chart is placed on. It defaults to the sheet
+	// holding the data, and is added when no sheet has that name.
+	Sheet string `json:"sheet,omitempty"`
+	// Anchor is the cell at the top left corner of the chart. Charts without
+	// one are stacked to the right of the data, or from B2 on other sheets.
+	Anchor string `json:"anchor,omitempty"`
+}
+
+// TableOptions describes the Excel table a sheet is written as.
+type TableOptions struct {
+	// Name is the table name used in structured references. It defaults to
+	// Table1, Table2 and so on.
+	Name string `json:"name,omitempty"`
+	// Style is a built-in table style. It defaults to TableStyleMedium2.
+	Style string `json:"style,omitempty"`
+	// BandedRows shades every other row and is on unless set to false.
+	BandedRows    *bool `json:"banded_rows,omitempty"`
+	BandedColumns bool  `json:"banded_columns,omitempty"`
+}
+
+// JsonOptions controls how a workbook is read by ConvertToJson.
+type JsonOptions struct {
+	// Typed emits numbers, booleans, null and ISO-8601 dates instead of the
+	// formatted cell text.
+	Typed bool
+	// Sheet selects the sheet to read by name or zero-based index. The first
+	// sheet is read when empty.
+	Sheet string
+	// AllSheets reads every sheet into an object keyed by sheet name.
+	AllSheets bool
+	// ExtraCells decides what happens to cells beyond the last header:
+	// ExtraCellsSynthesize (the default), ExtraCellsDrop or ExtraCellsError.
+	ExtraCells string
+	// HeaderRow is the one-based row holding the headers. It defaults to the
+	// first row of Range, or of the sheet.
+	HeaderRow int
+	// DetectHeader picks the first dense row as the header, skipping titles
+	// and notes above the table.
+	DetectHeader bool
+	// Range is an A1-style range such as B4:K200 that bounds the cells read.
+	Range string
+	// HeaderKeys maps header labels to the keys used in the JSON output.
+	HeaderKeys map[string]string
+	// Unflatten rebuilds nested objects and arrays from keys such as
+	// "address.city" and "tags[0]".
+	Unflatten bool
+	// Meta, when set, is the schema rows are checked against. Its columns
+	// are found by label or name, and their values are converted to the
+	// column type and checked against the column's validation.
+	Meta *MetaData
+}
+
+const (
+	ExtraCellsSynthesize = "synthesize"
+	ExtraCellsDrop       = "drop"
+	ExtraCellsError      = "error"
+)
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"io"
 	"testing"
 
 	"github.com/jagac/excelify/internal/converter"
@@ -41,9 +42,51 @@ func BenchmarkConversion(b *testing.B) {
 	}
 
 	for i := 0; i < b.N; i++ {
-		_, err := conv.ConvertToExcel(payload.Data, payload.Meta.Columns)
+		_, err := conv.ConvertToExcel([]types.Sheet{{Name: "Sheet1", Data: payload.Data, Meta: payload.Meta}}, types.ExcelOptions{})
 		if err != nil {
 			b.Fatalf("Error occurred during ConvertToJson: %v", err)
 		}
 	}
 }
+
+func BenchmarkStreamConversion(b *testing.B) {
+
+	conv := converter.NewConverter()
+	sheets := []types.Sheet{
+		{
+			Name: "Sheet1",
+			Data: GenerateDataItems(100000),
+			Meta: types.MetaData{
+				Columns: []types.ColumnMeta{
+					{
+						Name:              "name",
+						Type:              "STRING",
+						DefaultVisibility: "hidden",
+					},
+					{
+						Name: "age",
+						Type: "INTEGER",
+					},
+					{
+						Name: "email",
+						Type: "STRING",
+					},
+					{
+						Name: "salary",
+						Type: "FLOAT",
+					},
+					{
+						Name: "joined",
+						Type: "DATETIME",
+					},
+				},
+			},
+		},
+	}
+
+	for i := 0; i < b.N; i++ {
+		if err := conv.StreamToExcel(io.Discard, sheets, types.ExcelOptions{}); err != nil {
+			b.Fatalf("Error occurred during StreamToExcel: %v", err)
+		}
+	}
+}
@@ -1,9 +1,17 @@
 package tests
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/jagac/excelify/internal/server"
 	"github.com/xuri/excelize/v2"
 )
 
@@ -11,17 +19,21 @@ import (
 func GenerateDataItems(n int) []map[string]interface{} {
 	data := make([]map[string]interface{}, n)
 	for i := 0; i < n; i++ {
-		data[i] = map[string]interface{}{
-			"name":   fmt.Sprintf("Name %d", i),
-			"age":    20 + (i % 50),
-			"email":  fmt.Sprintf("email%d@example.com", i),
-			"salary": 30000 + (float64(i) * 10),
-			"joined": fmt.Sprintf("2022-01-%02d 15:04", (i%31)+1),
-		}
+		data[i] = GenerateDataItem(i)
 	}
 	return data
 }
 
+func GenerateDataItem(i int) map[string]interface{} {
+	return map[string]interface{}{
+		"name":   fmt.Sprintf("Name %d", i),
+		"age":    20 + (i % 50),
+		"email":  fmt.Sprintf("email%d@example.com", i),
+		"salary": 30000 + (float64(i) * 10),
+		"joined": fmt.Sprintf("2022-01-%02d 15:04", (i%31)+1),
+	}
+}
+
 
 func CheckExcelColumnsAndData(t *testing.T, filePath string, expectedColumns []string) {
 	f, err := excelize.OpenFile(filePath)
@@ -77,4 +89,103 @@ func CheckExcelColumnsAndData(t *testing.T, filePath string, expectedColumns []s
 			t.Errorf("expected name '%s' in row %d, got '%s'", expectedName, rowIndex+2, row[nameIndex])
 		}
 	}
-}
\ No newline at end of file
+}
+
+func CheckRowCount(t *testing.T, body io.Reader, expected int) {
+	t.Helper()
+	f, err := excelize.OpenReader(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	rows, err := f.GetRows(f.GetSheetName(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != expected {
+		t.Errorf("expected %d rows, got %d", expected, len(rows))
+	}
+}
+
+func SetSheetRows(t *testing.T, f *excelize.File, sheetName string, rows [][]interface{}) {
+	t.Helper()
+	for rowIndex, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, rowIndex+1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.SetSheetRow(sheetName, cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func NewUploadRequest(t *testing.T, f *excelize.File, fields map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	part, err := writer.CreateFormFile("file", "upload.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Write(part); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("POST", "/api/v1/conversions/to-json", &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func PostExcelRequest(t *testing.T, handler *server.Handler, payload interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	marshalled, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("POST", "/api/v1/conversions", bytes.NewBuffer(marshalled))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler.HandleJsonToExcel(rr, req)
+	return rr
+}
+
+// ReadZipParts returns the contents of the parts of an xlsx file by path.
+func ReadZipParts(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := make(map[string]string, len(reader.File))
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[file.Name] = string(content)
+	}
+	return parts
+}
@@ -3,12 +3,19 @@ package tests
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
+	"io"
+	"strings"
 
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
+	"runtime"
+	"slices"
 	"testing"
 
 	"github.com/jagac/excelify/internal/converter"
@@ -16,6 +23,7 @@ import (
 	"github.com/jagac/excelify/internal/server"
 	"github.com/jagac/excelify/internal/types"
 	"github.com/joho/godotenv"
+	"github.com/xuri/excelize/v2"
 	"go.uber.org/goleak"
 )
 
@@ -194,3 +202,1280 @@ func TestJsonHandler(t *testing.T) {
 	os.RemoveAll("logs")
 
 }
+
+func TestMultiSheetExport(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	handler := server.NewHandler(converter.NewConverter())
+
+	columns := []types.ColumnMeta{
+		{Name: "name", Type: "STRING"},
+		{Name: "age", Type: "INTEGER"},
+	}
+	payload := types.RequestJson{
+		Filename: "report.xlsx",
+		Sheets: []types.Sheet{
+			{Name: "Summary", Data: GenerateDataItems(10), Meta: types.MetaData{Columns: columns}},
+			{Name: "Details", Data: GenerateDataItems(100), Meta: types.MetaData{Columns: columns}},
+		},
+	}
+
+	marshalled, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("POST", "/api/v1/conversions", bytes.NewBuffer(marshalled))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler.HandleJsonToExcel(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	f, err := excelize.OpenReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	sheetNames := f.GetSheetList()
+	if len(sheetNames) != 2 || sheetNames[0] != "Summary" || sheetNames[1] != "Details" {
+		t.Fatalf("expected sheets [Summary Details], got %v", sheetNames)
+	}
+
+	rows, err := f.GetRows("Details")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 101 {
+		t.Errorf("expected 101 rows in Details, got %d", len(rows))
+	}
+}
+
+func TestStreamedRequestDecoding(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	handler := server.NewHandler(converter.NewConverter())
+
+	meta := `{"columns":[{"name":"name","type":"STRING"},{"name":"age","type":"INTEGER"}]}`
+	var rows bytes.Buffer
+	for _, item := range GenerateDataItems(2000) {
+		line, err := json.Marshal(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows.Write(line)
+		rows.WriteString("\n")
+	}
+
+	t.Run("should stream data that follows meta", func(t *testing.T) {
+		body := `{"meta":` + meta + `,"data":[` + strings.Join(strings.Split(strings.TrimSpace(rows.String()), "\n"), ",") + `],"filename":"streamed.xlsx"}`
+		req, err := http.NewRequest("POST", "/api/v1/conversions", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.HandleJsonToExcel(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+		if got := rr.Header().Get("Content-Disposition"); got != "attachment; filename=streamed.xlsx" {
+			t.Errorf("unexpected Content-Disposition %q", got)
+		}
+		CheckRowCount(t, rr.Body, 2001)
+	})
+
+	t.Run("should convert NDJSON with meta in a header", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "/api/v1/conversions?filename=lines.xlsx", bytes.NewReader(rows.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		req.Header.Set("X-Excelify-Meta", meta)
+
+		rr := httptest.NewRecorder()
+		handler.HandleJsonToExcel(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+		CheckRowCount(t, rr.Body, 2001)
+	})
+
+	t.Run("should require options to precede streamed data", func(t *testing.T) {
+		invalidRow := `{"name":"Name 0","age":"old"}`
+		bodies := map[string]int{
+			`{"meta":` + meta + `,"mode":"lenient","data":[` + invalidRow + `]}`:  http.StatusOK,
+			`{"data":[` + invalidRow + `],"mode":"lenient","meta":` + meta + `}`:  http.StatusOK,
+			`{"meta":` + meta + `,"data":[` + invalidRow + `],"mode":"lenient"}`:  http.StatusBadRequest,
+			`{"meta":` + meta + `,"data":[` + invalidRow + `],"mode":"bogus"}`:    http.StatusBadRequest,
+			`{"meta":` + meta + `,"data":[` + invalidRow + `],"explode":"items"}`: http.StatusBadRequest,
+		}
+		for _, field := range []string{`"template":"invoice"`, `"values":{"total":1}`, `"sheets":[]`} {
+			bodies[`{"meta":`+meta+`,"data":[{"name":"Name 0","age":20}],`+field+`}`] = http.StatusBadRequest
+		}
+		for body, want := range bodies {
+			req, err := http.NewRequest("POST", "/api/v1/conversions", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			handler.HandleJsonToExcel(rr, req)
+			if rr.Code != want {
+				t.Errorf("expected status code %d for %s, got %d", want, body, rr.Code)
+			}
+		}
+	})
+
+	t.Run("should reject a truncated stream", func(t *testing.T) {
+		body := `{"meta":` + meta + `,"data":[{"name":"Name 0","age":20},{"name":`
+		req, err := http.NewRequest("POST", "/api/v1/conversions", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.HandleJsonToExcel(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
+
+func TestColumnTypes(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	handler := server.NewHandler(converter.NewConverter())
+
+	t.Run("should write boolean, date, time, currency and duration values", func(t *testing.T) {
+		payload := types.RequestJson{Filename: "types.xlsx"}
+		payload.Data = []map[string]interface{}{{
+			"active":   "yes",
+			"day":      "2024-03-01",
+			"start":    "08:30",
+			"price":    "12.5",
+			"duration": "PT1H30M",
+		}}
+		payload.Meta.Columns = []types.ColumnMeta{
+			{Name: "active", Type: "BOOLEAN"},
+			{Name: "day", Type: "DATE"},
+			{Name: "start", Type: "TIME"},
+			{Name: "price", Type: "CURRENCY", Currency: "EUR"},
+			{Name: "duration", Type: "DURATION"},
+		}
+
+		rr := PostExcelRequest(t, handler, payload)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		f, err := excelize.OpenReader(rr.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		expected := map[string]string{
+			"A2": "TRUE",
+			"B2": "2024-03-01",
+			"C2": "08:30:00",
+			"D2": "€12.50",
+			"E2": "1:30:00",
+		}
+		for cell, value := range expected {
+			got, err := f.GetCellValue("Sheet1", cell)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != value {
+				t.Errorf("expected %s to be %q, got %q", cell, value, got)
+			}
+		}
+	})
+
+	t.Run("should apply custom number formats", func(t *testing.T) {
+		payload := types.RequestJson{Filename: "formats.xlsx"}
+		payload.Data = []map[string]interface{}{{"ratio": 1234.5678, "joined": "2022-01-15 15:04", "share": 0.25}}
+		payload.Meta.Columns = []types.ColumnMeta{
+			{Name: "ratio", Type: "FLOAT", Format: "#,##0.000"},
+			{Name: "joined", Type: "DATETIME", Format: "dd.mm.yyyy hh:mm"},
+			{Name: "share", Type: "PERCENTAGE", Format: "0.0%"},
+		}
+
+		rr := PostExcelRequest(t, handler, payload)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		f, err := excelize.OpenReader(rr.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		expected := map[string]string{"A2": "1,234.568", "B2": "15.01.2022 15:04", "C2": "25.0%"}
+		for cell, value := range expected {
+			got, err := f.GetCellValue("Sheet1", cell)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != value {
+				t.Errorf("expected %s to be %q, got %q", cell, value, got)
+			}
+		}
+	})
+
+	t.Run("should parse datetime inputs into the column timezone", func(t *testing.T) {
+		payload := types.RequestJson{Filename: "datetimes.xlsx"}
+		payload.Data = []map[string]interface{}{
+			{"at": "2024-07-01T10:00:00Z", "local": "2024-07-01T10:00:00Z"},
+			{"at": 1719828000, "local": "1719828000000"},
+			{"at": "2024-07-01", "local": "2024-07-01 10:00:30"},
+		}
+		payload.Meta.Columns = []types.ColumnMeta{
+			{Name: "at", Type: "DATETIME"},
+			{Name: "local", Type: "DATETIME", Timezone: "Europe/Berlin", Format: "yyyy-mm-dd hh:mm:ss"},
+		}
+
+		rr := PostExcelRequest(t, handler, payload)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		f, err := excelize.OpenReader(rr.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		expected := map[string]string{
+			"A2": "2024-07-01 10:00",
+			"B2": "2024-07-01 12:00:00",
+			"A3": "2024-07-01 10:00",
+			"B3": "2024-07-01 12:00:00",
+			"A4": "2024-07-01 00:00",
+			"B4": "2024-07-01 12:00:30",
+		}
+		for cell, value := range expected {
+			got, err := f.GetCellValue("Sheet1", cell)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != value {
+				t.Errorf("expected %s to be %q, got %q", cell, value, got)
+			}
+		}
+	})
+
+	t.Run("should reject unknown column types", func(t *testing.T) {
+		payload := types.RequestJson{Filename: "types.xlsx"}
+		payload.Data = []map[string]interface{}{{"name": "Name 0"}}
+		payload.Meta.Columns = []types.ColumnMeta{{Name: "name", Type: "TEXT"}}
+
+		rr := PostExcelRequest(t, handler, payload)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
+
+func TestConversionModes(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	handler := server.NewHandler(converter.NewConverter())
+
+	payload := types.RequestJson{Filename: "modes.xlsx"}
+	payload.Data = GenerateDataItems(20)
+	payload.Data[3]["age"] = "unknown"
+	payload.Data[7]["joined"] = "yesterday"
+	payload.Meta.Columns = []types.ColumnMeta{
+		{Name: "name", Type: "STRING"},
+		{Name: "age", Type: "INTEGER"},
+		{Name: "joined", Type: "DATETIME"},
+	}
+
+	t.Run("should list every offending cell in strict mode", func(t *testing.T) {
+		rr := PostExcelRequest(t, handler, payload)
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected status code %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+		}
+
+		var body struct {
+			Error types.ConversionError `json:"error"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Error.Total != 2 || len(body.Error.Errors) != 2 {
+			t.Fatalf("expected 2 errors, got %+v", body.Error)
+		}
+		first := body.Error.Errors[0]
+		if first.Row != 3 || first.Cell != "B5" || first.Column != "age" || first.Value != "unknown" {
+			t.Errorf("unexpected first error %+v", first)
+		}
+	})
+
+	t.Run("should write an Errors sheet in lenient mode", func(t *testing.T) {
+		lenient := payload
+		lenient.Mode = types.ModeLenient
+		rr := PostExcelRequest(t, handler, lenient)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		f, err := excelize.OpenReader(rr.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		if value, _ := f.GetCellValue("Sheet1", "B5"); value != "unknown" {
+			t.Errorf("expected the invalid value to be kept as text, got %q", value)
+		}
+		rows, err := f.GetRows("Errors")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 3 || rows[2][2] != "C9" {
+			t.Errorf("unexpected Errors sheet %v", rows)
+		}
+	})
+}
+
+func TestColumnInference(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	handler := server.NewHandler(converter.NewConverter())
+
+	rows := []string{
+		`{"zeta":"a","count":1,"price":2,"ok":true,"day":"2024-03-01","seen":null}`,
+		`{"zeta":"b","count":2,"price":2.5,"ok":false,"day":"2024-03-02","seen":"2024-03-02T10:30:00Z","extra":"x"}`,
+	}
+	expectedHeaders := []string{"zeta", "count", "price", "ok", "day", "seen", "extra"}
+	expectedCells := map[string]string{
+		"B3": "2",
+		"C2": "2.00",
+		"C3": "2.50",
+		"D2": "TRUE",
+		"E3": "2024-03-02",
+		"F3": "2024-03-02 10:30",
+		"G3": "x",
+	}
+
+	check := func(t *testing.T, rr *httptest.ResponseRecorder) {
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		f, err := excelize.OpenReader(rr.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		sheetRows, err := f.GetRows("Sheet1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sheetRows) != 3 || !reflect.DeepEqual(sheetRows[0], expectedHeaders) {
+			t.Fatalf("unexpected rows %v", sheetRows)
+		}
+		for cell, expected := range expectedCells {
+			if value, _ := f.GetCellValue("Sheet1", cell); value != expected {
+				t.Errorf("expected %s to be %q, got %q", cell, expected, value)
+			}
+		}
+	}
+
+	t.Run("should infer columns in first-seen key order", func(t *testing.T) {
+		body := `{"filename":"inferred.xlsx","data":[` + strings.Join(rows, ",") + `]}`
+		req, err := http.NewRequest("POST", "/api/v1/conversions", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.HandleJsonToExcel(rr, req)
+		check(t, rr)
+	})
+
+	t.Run("should infer columns from NDJSON rows without meta", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "/api/v1/conversions", strings.NewReader(strings.Join(rows, "\n")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+
+		rr := httptest.NewRecorder()
+		handler.HandleJsonToExcel(rr, req)
+		check(t, rr)
+	})
+
+	t.Run("should reject streamed keys missing from the inferred columns", func(t *testing.T) {
+		var body strings.Builder
+		for i := 0; i < 1000; i++ {
+			fmt.Fprintf(&body, "{\"zeta\":\"%d\"}\n", i)
+		}
+		body.WriteString(`{"zeta":"late","extra":"x"}`)
+		req, err := http.NewRequest("POST", "/api/v1/conversions", strings.NewReader(body.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+
+		rr := httptest.NewRecorder()
+		handler.HandleJsonToExcel(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), `data row 1000 has key "extra"`) {
+			t.Errorf("expected the error to name the row and key, got %s", rr.Body.String())
+		}
+	})
+}
+
+func TestNestedData(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	handler := server.NewHandler(converter.NewConverter())
+
+	body := `{
+		"filename": "orders.xlsx",
+		"data": [
+			{"id": 1, "customer": {"name": "Ann", "address": {"city": "Oslo"}}, "tags": ["new", "vip"], "lines": [{"sku": "A", "qty": 2}, {"sku": "B", "qty": 1}]},
+			{"id": 2, "customer": {"name": "Bob", "address": {"city": "Rome"}}, "tags": [], "lines": []}
+		]%s
+	}`
+
+	post := func(t *testing.T, extra string) [][]string {
+		req, err := http.NewRequest("POST", "/api/v1/conversions", strings.NewReader(fmt.Sprintf(body, extra)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.HandleJsonToExcel(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		f, err := excelize.OpenReader(rr.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		rows, err := f.GetRows("Sheet1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+
+	t.Run("should flatten objects and join arrays", func(t *testing.T) {
+		rows := post(t, `, "array_delimiter": "|"`)
+		expected := [][]string{
+			{"id", "customer.address.city", "customer.name", "tags", "lines"},
+			{"1", "Oslo", "Ann", "new|vip", `{"qty":2,"sku":"A"}|{"qty":1,"sku":"B"}`},
+			{"2", "Rome", "Bob"},
+		}
+		if !reflect.DeepEqual(rows, expected) {
+			t.Errorf("expected %v, got %v", expected, rows)
+		}
+	})
+
+	t.Run("should explode arrays into rows", func(t *testing.T) {
+		rows := post(t, `, "explode": "lines", "meta": {"columns": [
+			{"name": "id", "type": "INTEGER"},
+			{"name": "$.customer.name", "label": "Customer", "type": "STRING"},
+			{"name": "tags[0]", "type": "STRING"},
+			{"name": "lines.sku", "type": "STRING"},
+			{"name": "lines.qty", "type": "INTEGER"}
+		]}`)
+		expected := [][]string{
+			{"id", "Customer", "tags[0]", "lines.sku", "lines.qty"},
+			{"1", "Ann", "new", "A", "2"},
+			{"1", "Ann", "new", "B", "1"},
+			{"2", "Bob"},
+		}
+		if !reflect.DeepEqual(rows, expected) {
+			t.Errorf("expected %v, got %v", expected, rows)
+		}
+	})
+}
+
+func TestTableExport(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	handler := server.NewHandler(converter.NewConverter())
+
+	bandedRows := false
+	meta := types.MetaData{
+		Columns: []types.ColumnMeta{
+			{Name: "name", Type: "STRING"},
+			{Name: "age", Type: "INTEGER", Total: "average"},
+			{Name: "salary", Type: "FLOAT", Total: "sum"},
+		},
+		Table: &types.TableOptions{Name: "Staff", Style: "TableStyleLight9", BandedRows: &bandedRows},
+	}
+
+	check := func(t *testing.T, f *excelize.File) {
+		tables, err := f.GetTables("Sheet1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tables) != 1 {
+			t.Fatalf("expected 1 table, got %d", len(tables))
+		}
+		table := tables[0]
+		if table.Name != "Staff" || table.Range != "A1:C11" || table.StyleName != "TableStyleLight9" || *table.ShowRowStripes {
+			t.Errorf("unexpected table %+v", table)
+		}
+
+		if value, _ := f.GetCellValue("Sheet1", "A12"); value != "Total" {
+			t.Errorf("expected a totals label, got %q", value)
+		}
+		for cell, expected := range map[string]string{"B12": "SUBTOTAL(101,B2:B11)", "C12": "SUBTOTAL(109,C2:C11)"} {
+			if formula, _ := f.GetCellFormula("Sheet1", cell); formula != expected {
+				t.Errorf("expected %s to be %q, got %q", cell, expected, formula)
+			}
+		}
+	}
+
+	t.Run("should add a table with totals to streamed sheets", func(t *testing.T) {
+		payload := types.RequestJson{Filename: "table.xlsx", Data: GenerateDataItems(10), Meta: meta}
+		rr := PostExcelRequest(t, handler, payload)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		f, err := excelize.OpenReader(rr.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		check(t, f)
+	})
+
+	t.Run("should add a table with totals to buffered sheets", func(t *testing.T) {
+		buffer, err := converter.NewConverter().ConvertToExcel([]types.Sheet{{Data: GenerateDataItems(10), Meta: meta}}, types.ExcelOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := excelize.OpenReader(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		check(t, f)
+	})
+}
+
+func TestFormulaColumns(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	handler := server.NewHandler(converter.NewConverter())
+
+	payload := types.RequestJson{Filename: "formulas.xlsx", Data: GenerateDataItems(5)}
+	payload.Meta.Columns = []types.ColumnMeta{
+		{Name: "name", Type: "STRING", Total: "count"},
+		{Name: "age", Type: "INTEGER", Total: "MAX"},
+		{Name: "salary", Type: "FLOAT", Total: "sum"},
+		{Name: "monthly", Type: "FORMULA", Formula: "=C{row}/12", Format: "#,##0.00", Total: "average"},
+	}
+
+	t.Run("should write row formulas and a summary row", func(t *testing.T) {
+		rr := PostExcelRequest(t, handler, payload)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		f, err := excelize.OpenReader(rr.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		expected := map[string]string{
+			"D2": "C2/12",
+			"D6": "C6/12",
+			"A8": "SUBTOTAL(103,A2:A6)",
+			"B8": "SUBTOTAL(104,B2:B6)",
+			"C8": "SUBTOTAL(109,C2:C6)",
+			"D8": "SUBTOTAL(101,D2:D6)",
+		}
+		for cell, formula := range expected {
+			if got, _ := f.GetCellFormula("Sheet1", cell); got != formula {
+				t.Errorf("expected %s to be %q, got %q", cell, formula, got)
+			}
+		}
+		if value, _ := f.CalcCellValue("Sheet1", "D2"); value != "2,500.00" {
+			t.Errorf("expected D2 to calculate to 2,500.00, got %q", value)
+		}
+		if value, _ := f.GetCellValue("Sheet1", "A7"); value != "" {
+			t.Errorf("expected a blank row above the summary row, got %q", value)
+		}
+	})
+
+	t.Run("should reject formula columns without a formula", func(t *testing.T) {
+		invalid := payload
+		invalid.Meta.Columns = []types.ColumnMeta{{Name: "monthly", Type: "FORMULA"}}
+		rr := PostExcelRequest(t, handler, invalid)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
+
+func TestConditionalFormats(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	handler := server.NewHandler(converter.NewConverter())
+
+	zero, top := 0.0, 3.0
+	payload := types.RequestJson{Filename: "formats.xlsx", Data: GenerateDataItems(20)}
+	payload.Meta.Columns = []types.ColumnMeta{
+		{Name: "name", Type: "STRING", ConditionalFormats: []types.ConditionalFormat{{Type: "duplicates"}}},
+		{Name: "age", Type: "INTEGER", ConditionalFormats: []types.ConditionalFormat{
+			{Type: "threshold", Criteria: "<", Value: &zero, FontColor: "FF0000"},
+			{Type: "top", Value: &top, FillColor: "C6EFCE"},
+		}},
+		{Name: "salary", Type: "FLOAT", ConditionalFormats: []types.ConditionalFormat{
+			{Type: "data_bar"},
+			{Type: "color_scale", Colors: []string{"FFFFFF", "63BE7B"}},
+			{Type: "icon_set", IconStyle: "3TrafficLights1"},
+		}},
+	}
+
+	t.Run("should apply rules over the data range", func(t *testing.T) {
+		rr := PostExcelRequest(t, handler, payload)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		f, err := excelize.OpenReader(rr.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		formats, err := f.GetConditionalFormats("Sheet1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string][]string{
+			"A2:A21": {"duplicate"},
+			"B2:B21": {"cell", "top"},
+			"C2:C21": {"data_bar", "2_color_scale", "icon_set"},
+		}
+		for rangeRef, ruleTypes := range expected {
+			rules := formats[rangeRef]
+			if len(rules) != len(ruleTypes) {
+				t.Fatalf("expected %d rules on %s, got %+v", len(ruleTypes), rangeRef, formats)
+			}
+			for i, ruleType := range ruleTypes {
+				if rules[i].Type != ruleType {
+					t.Errorf("expected rule %d on %s to be %q, got %q", i, rangeRef, ruleType, rules[i].Type)
+				}
+			}
+		}
+		if rule := formats["B2:B21"][0]; rule.Criteria != "less than" || rule.Value != "0" {
+			t.Errorf("unexpected threshold rule %+v", rule)
+		}
+	})
+
+	t.Run("should reject invalid rules", func(t *testing.T) {
+		invalid := payload
+		invalid.Meta.Columns = []types.ColumnMeta{{Name: "age", Type: "INTEGER", ConditionalFormats: []types.ConditionalFormat{{Type: "threshold", Criteria: ">"}}}}
+		rr := PostExcelRequest(t, handler, invalid)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
+
+func TestDataValidation(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	handler := server.NewHandler(converter.NewConverter())
+
+	countries := make([]string, 60)
+	for i := range countries {
+		countries[i] = fmt.Sprintf("Country %d", i)
+	}
+	payload := types.RequestJson{Filename: "validation.xlsx", Data: GenerateDataItems(5)}
+	payload.Meta.EntryRows = 10
+	payload.Meta.Columns = []types.ColumnMeta{
+		{Name: "name", Type: "STRING", Validation: &types.Validation{MaxLength: 40}},
+		{Name: "age", Type: "INTEGER", Validation: &types.Validation{Min: 18.0, Max: 67.0}},
+		{Name: "joined", Type: "DATE", InputFormats: []string{"2006-01-02 15:04"}, Validation: &types.Validation{Min: "2020-01-01 00:00"}},
+		{Name: "team", Type: "STRING", Validation: &types.Validation{Enum: []string{"Sales", "Ops"}}},
+		{Name: "country", Type: "STRING", Validation: &types.Validation{Enum: countries}},
+	}
+
+	t.Run("should add validations over data and entry rows", func(t *testing.T) {
+		rr := PostExcelRequest(t, handler, payload)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		f, err := excelize.OpenReader(rr.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		validations, err := f.GetDataValidations("Sheet1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string][3]string{
+			"A2:A16": {"textLength", "40", ""},
+			"B2:B16": {"whole", "18", "67"},
+			"C2:C16": {"date", "43831", ""},
+			"D2:D16": {"list", `"Sales,Ops"`, ""},
+			"E2:E16": {"list", "'Lists'!$A$1:$A$60", ""},
+		}
+		if len(validations) != len(expected) {
+			t.Fatalf("expected %d validations, got %d", len(expected), len(validations))
+		}
+		for _, dv := range validations {
+			want, ok := expected[dv.Sqref]
+			if !ok || dv.Type != want[0] || dv.Formula1 != want[1] || dv.Formula2 != want[2] {
+				t.Errorf("unexpected validation on %s: %s %q %q", dv.Sqref, dv.Type, dv.Formula1, dv.Formula2)
+			}
+		}
+
+		if visible, _ := f.GetSheetVisible("Lists"); visible {
+			t.Error("expected the Lists sheet to be hidden")
+		}
+		if value, _ := f.GetCellValue("Lists", "A60"); value != "Country 59" {
+			t.Errorf("unexpected list value %q", value)
+		}
+
+		dataStyle, _ := f.GetCellStyle("Sheet1", "C2")
+		entryStyle, _ := f.GetCellStyle("Sheet1", "C16")
+		if dataStyle != entryStyle {
+			t.Errorf("expected entry rows to share the data style %d, got %d", dataStyle, entryStyle)
+		}
+	})
+
+	t.Run("should reject combined constraints", func(t *testing.T) {
+		invalid := payload
+		invalid.Meta.Columns = []types.ColumnMeta{{Name: "age", Type: "INTEGER", Validation: &types.Validation{Enum: []string{"1"}, Max: 2.0}}}
+		rr := PostExcelRequest(t, handler, invalid)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
+
+func TestCharts(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	handler := server.NewHandler(converter.NewConverter())
+
+	payload := types.RequestJson{Filename: "kpis.xlsx", Sheets: []types.Sheet{{
+		Name: "KPIs",
+		Data: GenerateDataItems(5),
+		Meta: types.MetaData{
+			Columns: []types.ColumnMeta{
+				{Name: "name", Type: "STRING"},
+				{Name: "age", Type: "INTEGER"},
+				{Name: "salary", Type: "CURRENCY", Currency: "EUR"},
+			},
+			Charts: []types.Chart{
+				{Type: "line", Title: "Salary", Category: "name", Series: []string{"salary"}},
+				{Type: "column", Category: "name", Series: []string{"age", "salary"}, Sheet: "Charts", Anchor: "C3"},
+			},
+		},
+	}}}
+
+	t.Run("should draw charts over the data rows", func(t *testing.T) {
+		rr := PostExcelRequest(t, handler, payload)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		parts := ReadZipParts(t, rr.Body.Bytes())
+		line, column := html.UnescapeString(parts["xl/charts/chart1.xml"]), html.UnescapeString(parts["xl/charts/chart2.xml"])
+		for _, want := range []string{"<lineChart>", "Salary", "'KPIs'!$C$2:$C$6", "'KPIs'!$A$2:$A$6", "'KPIs'!$C$1"} {
+			if !strings.Contains(line, want) {
+				t.Errorf("expected the line chart to contain %q", want)
+			}
+		}
+		for _, want := range []string{`<barDir val="col">`, "'KPIs'!$B$2:$B$6", "'KPIs'!$C$2:$C$6"} {
+			if !strings.Contains(column, want) {
+				t.Errorf("expected the column chart to contain %q", want)
+			}
+		}
+		if !strings.Contains(parts["xl/worksheets/sheet1.xml"], "<drawing ") {
+			t.Error("expected the data sheet to reference its drawing")
+		}
+		if !strings.Contains(parts["xl/drawings/drawing1.xml"], "<xdr:col>4</xdr:col>") {
+			t.Error("expected the line chart to be placed right of the data")
+		}
+		if !strings.Contains(parts["xl/drawings/drawing2.xml"], "<xdr:col>2</xdr:col><xdr:colOff>0</xdr:colOff><xdr:row>2</xdr:row>") {
+			t.Error("expected the column chart at C3")
+		}
+
+		f, err := excelize.OpenReader(bytes.NewReader(rr.Body.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if !reflect.DeepEqual(f.GetSheetList(), []string{"KPIs", "Charts"}) {
+			t.Errorf("unexpected sheets %v", f.GetSheetList())
+		}
+	})
+
+	t.Run("should draw charts when not streaming", func(t *testing.T) {
+		buffer, err := converter.NewConverter().ConvertToExcel(payload.Sheets, types.ExcelOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts := ReadZipParts(t, buffer.Bytes())
+		if !strings.Contains(html.UnescapeString(parts["xl/charts/chart1.xml"]), "'KPIs'!$C$2:$C$6") || parts["xl/charts/chart2.xml"] == "" {
+			t.Error("expected both charts to be drawn")
+		}
+	})
+
+	invalid := map[string]types.Chart{
+		"unknown type":        {Type: "radar", Series: []string{"age"}},
+		"text series":         {Type: "bar", Series: []string{"name"}},
+		"missing series":      {Type: "bar", Series: []string{"bonus"}},
+		"pie with two series": {Type: "pie", Series: []string{"age", "salary"}},
+		"other data sheet":    {Type: "bar", Series: []string{"age"}, Sheet: "Other"},
+		"invalid sheet names": {Type: "bar", Series: []string{"age"}, Sheet: "Q1/Q2"},
+		"long sheet names":    {Type: "bar", Series: []string{"age"}, Sheet: strings.Repeat("x", 32)},
+	}
+	for name, chart := range invalid {
+		t.Run("should reject "+name, func(t *testing.T) {
+			request := payload
+			sheet := payload.Sheets[0]
+			sheet.Meta.Charts = []types.Chart{chart}
+			request.Sheets = []types.Sheet{sheet, {Name: "Other", Data: GenerateDataItems(1)}}
+			rr := PostExcelRequest(t, handler, request)
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+		})
+	}
+}
+
+func TestPivotTable(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	handler := server.NewHandler(converter.NewConverter())
+
+	data := GenerateDataItems(6)
+	for i, row := range data {
+		row["region"] = []string{"EU", "US"}[i%2]
+	}
+	payload := types.RequestJson{Filename: "sales.xlsx", Sheets: []types.Sheet{{
+		Name: "Sales",
+		Data: data,
+		Meta: types.MetaData{
+			Columns: []types.ColumnMeta{
+				{Name: "region", Label: "Region", Type: "STRING"},
+				{Name: "name", Type: "STRING"},
+				{Name: "salary", Label: "Salary", Type: "FLOAT"},
+				{Name: "age", Type: "INTEGER"},
+			},
+			Pivot: &types.PivotTable{
+				Rows: []string{"region"},
+				Data: []types.PivotValue{{Column: "salary"}, {Column: "age", Function: "average", Label: "Mean age"}},
+			},
+		},
+	}}}
+
+	check := func(t *testing.T, body []byte) {
+		t.Helper()
+		parts := ReadZipParts(t, body)
+		table := parts["xl/pivotTables/pivotTable1.xml"]
+		for _, want := range []string{`name="Sum of Salary"`, `subtotal="sum"`, `name="Mean age"`, `subtotal="average"`, `<location ref="A3:B4"`} {
+			if !strings.Contains(table, want) {
+				t.Errorf("expected the pivot table to contain %q", want)
+			}
+		}
+		cache := parts["xl/pivotCache/pivotCacheDefinition1.xml"]
+		for _, want := range []string{`ref="A1:D7" sheet="Sales"`, `name="Region"`, `name="Salary"`} {
+			if !strings.Contains(cache, want) {
+				t.Errorf("expected the pivot cache to contain %q", want)
+			}
+		}
+
+		f, err := excelize.OpenReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if !reflect.DeepEqual(f.GetSheetList(), []string{"Sales", "Pivot"}) {
+			t.Errorf("unexpected sheets %v", f.GetSheetList())
+		}
+		rows, err := f.GetRows("Sales")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 7 || rows[0][0] != "Region" || rows[6][1] != "Name 5" {
+			t.Errorf("unexpected data rows %v", rows)
+		}
+	}
+
+	t.Run("should add a pivot table over the data", func(t *testing.T) {
+		rr := PostExcelRequest(t, handler, payload)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		check(t, rr.Body.Bytes())
+	})
+
+	t.Run("should add a pivot table when not streaming", func(t *testing.T) {
+		buffer, err := converter.NewConverter().ConvertToExcel(payload.Sheets, types.ExcelOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, buffer.Bytes())
+	})
+
+	t.Run("should leave entry rows out of the pivot range", func(t *testing.T) {
+		request := payload
+		sheet := payload.Sheets[0]
+		sheet.Meta.EntryRows = 3
+		request.Sheets = []types.Sheet{sheet}
+		rr := PostExcelRequest(t, handler, request)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		if cache := ReadZipParts(t, rr.Body.Bytes())["xl/pivotCache/pivotCacheDefinition1.xml"]; !strings.Contains(cache, `ref="A1:D7" sheet="Sales"`) {
+			t.Errorf("expected the pivot cache to end at the last data row, got %s", cache)
+		}
+	})
+
+	t.Run("should reject data sheets with ! in their name", func(t *testing.T) {
+		request := payload
+		sheet := payload.Sheets[0]
+		sheet.Name = "Sales!"
+		request.Sheets = []types.Sheet{sheet}
+		if rr := PostExcelRequest(t, handler, request); rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	invalid := map[string]types.PivotTable{
+		"no data fields":   {Rows: []string{"region"}},
+		"unknown column":   {Rows: []string{"country"}, Data: []types.PivotValue{{Column: "salary"}}},
+		"unknown function": {Data: []types.PivotValue{{Column: "salary", Function: "median"}}},
+		"taken label":      {Data: []types.PivotValue{{Column: "salary", Label: "Region"}}},
+		"data sheet":       {Data: []types.PivotValue{{Column: "salary"}}, Sheet: "Sales"},
+		"invalid sheet":    {Data: []types.PivotValue{{Column: "salary"}}, Sheet: "Q1/Q2"},
+		"sheet with !":     {Data: []types.PivotValue{{Column: "salary"}}, Sheet: "Sum!"},
+	}
+	for name, pivot := range invalid {
+		t.Run("should reject "+name, func(t *testing.T) {
+			request := payload
+			sheet := payload.Sheets[0]
+			sheet.Meta.Pivot = &pivot
+			request.Sheets = []types.Sheet{sheet}
+			rr := PostExcelRequest(t, handler, request)
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+		})
+	}
+}
+
+func TestCSVExport(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	handler := server.NewHandler(converter.NewConverter())
+
+	payload := types.RequestJson{
+		Filename: "report.csv",
+		Data: []map[string]interface{}{
+			{"name": "Doe, John", "note": `say "hi"`, "age": 30, "salary": 55000.5, "joined": "2022-01-15 15:04", "born": "1990-02-03", "active": "yes", "start": "08:30", "took": "PT1H30M"},
+			{"name": "Jane", "age": "41", "salary": 1.25, "joined": 1700000000, "born": "1985-12-24", "active": false, "start": "17:05:09", "took": 90061},
+		},
+		Meta: types.MetaData{Columns: []types.ColumnMeta{
+			{Name: "name", Label: "Full name", Type: "STRING"},
+			{Name: "note", Type: "STRING"},
+			{Name: "age", Type: "INTEGER"},
+			{Name: "salary", Type: "FLOAT"},
+			{Name: "joined", Type: "DATETIME", Timezone: "Europe/Berlin"},
+			{Name: "born", Type: "DATE"},
+			{Name: "active", Type: "BOOLEAN"},
+			{Name: "start", Type: "TIME"},
+			{Name: "took", Type: "DURATION"},
+			{Name: "double", Type: "FORMULA", Formula: "=C{row}*2"},
+		}},
+	}
+
+	post := func(t *testing.T, payload interface{}, accept string) *httptest.ResponseRecorder {
+		t.Helper()
+		marshalled, err := json.Marshal(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest("POST", "/api/v1/conversions", bytes.NewBuffer(marshalled))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", accept)
+		rr := httptest.NewRecorder()
+		handler.HandleJsonToExcel(rr, req)
+		return rr
+	}
+	check := func(t *testing.T, rr *httptest.ResponseRecorder, contentType, want string) {
+		t.Helper()
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		if got := rr.Header().Get("Content-Type"); got != contentType {
+			t.Errorf("expected content type %q, got %q", contentType, got)
+		}
+		if got := rr.Body.String(); got != want {
+			t.Errorf("unexpected output:\n%s\nwant:\n%s", got, want)
+		}
+	}
+
+	t.Run("should write CSV for the format field", func(t *testing.T) {
+		request := payload
+		request.Format = "csv"
+		check(t, post(t, request, ""), "text/csv; charset=utf-8", `Full name,note,age,salary,joined,born,active,start,took,double
+"Doe, John","say ""hi""",30,55000.5,2022-01-15 16:04:00,1990-02-03,true,08:30:00,1:30:00,=C2*2
+Jane,,41,1.25,2023-11-14 23:13:20,1985-12-24,false,17:05:09,25:01:01,=C3*2
+`)
+	})
+
+	t.Run("should write TSV for the Accept header", func(t *testing.T) {
+		request := payload
+		request.Meta.Columns = payload.Meta.Columns[:3]
+		check(t, post(t, request, "text/tab-separated-values, application/json"), "text/tab-separated-values; charset=utf-8",
+			"Full name\tnote\tage\nDoe, John\t\"say \"\"hi\"\"\"\t30\nJane\t\t41\n")
+	})
+
+	t.Run("should prefer the format with the highest q-value", func(t *testing.T) {
+		request := payload
+		request.Meta.Columns = payload.Meta.Columns[:1]
+		xlsx := "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		for accept, want := range map[string]string{
+			"text/csv;q=0.1, " + xlsx:                       types.ContentTypeXLSX,
+			"text/csv;q=0, text/tab-separated-values;q=0.2": "text/tab-separated-values; charset=utf-8",
+			xlsx + ";q=0.5, text/csv;q=0.9, text/html":      "text/csv; charset=utf-8",
+			"text/csv;q=0": types.ContentTypeXLSX,
+		} {
+			rr := post(t, request, accept)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status code %d for %q, got %d", http.StatusOK, accept, rr.Code)
+			}
+			if got := rr.Header().Get("Content-Type"); got != want {
+				t.Errorf("expected content type %q for %q, got %q", want, accept, got)
+			}
+		}
+	})
+
+	t.Run("should apply CSV options", func(t *testing.T) {
+		request := payload
+		request.Meta.Columns = []types.ColumnMeta{payload.Meta.Columns[0], payload.Meta.Columns[4], payload.Meta.Columns[5]}
+		request.Format = "csv"
+		request.CSV = &types.CSVOptions{Delimiter: ";", Quoting: "all", BOM: true, DateFormat: "02.01.2006", DatetimeFormat: "02.01.2006 15:04"}
+		check(t, post(t, request, "text/tab-separated-values"), "text/csv; charset=utf-8", "\uFEFF"+`"Full name";"joined";"born"
+"Doe, John";"15.01.2022 16:04";"03.02.1990"
+"Jane";"14.11.2023 23:13";"24.12.1985"
+`)
+	})
+
+	t.Run("should write XLSX by default", func(t *testing.T) {
+		rr := post(t, payload, "text/html, */*")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+		CheckRowCount(t, rr.Body, 3)
+	})
+
+	t.Run("should stream NDJSON rows as CSV", func(t *testing.T) {
+		body := `{"name":"a","age":1}` + "\n" + `{"name":"b","age":2}`
+		req, err := http.NewRequest("POST", "/api/v1/conversions?format=csv&csv=%7B%22delimiter%22%3A%22%7C%22%7D", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		rr := httptest.NewRecorder()
+		handler.HandleJsonToExcel(rr, req)
+		check(t, rr, "text/csv; charset=utf-8", "name|age\na|1\nb|2\n")
+	})
+
+	t.Run("should require the format to precede streamed data", func(t *testing.T) {
+		meta := `{"columns":[{"name":"name","type":"STRING"}]}`
+		bodies := map[string]int{
+			`{"meta":` + meta + `,"format":"csv","data":[{"name":"a"}]}`:     http.StatusOK,
+			`{"meta":` + meta + `,"data":[{"name":"a"}],"format":"csv"}`:     http.StatusBadRequest,
+			`{"meta":` + meta + `,"data":[{"name":"a"}],"csv":{"bom":true}}`: http.StatusBadRequest,
+		}
+		for body, want := range bodies {
+			req, err := http.NewRequest("POST", "/api/v1/conversions", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			handler.HandleJsonToExcel(rr, req)
+			if rr.Code != want {
+				t.Errorf("expected status code %d for %s, got %d", want, body, rr.Code)
+			}
+			if want == http.StatusOK && rr.Body.String() != "name\na\n" {
+				t.Errorf("expected CSV output for %s, got %q", body, rr.Body.String())
+			}
+		}
+	})
+
+	t.Run("should list invalid values in strict mode", func(t *testing.T) {
+		request := payload
+		request.Format = "csv"
+		request.Data = []map[string]interface{}{{"age": "many"}}
+		if rr := post(t, request, ""); rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+		}
+	})
+
+	invalid := map[string]types.RequestJson{
+		"unknown formats": {Format: "pdf", Data: payload.Data},
+		"long delimiters": {Format: "csv", CSV: &types.CSVOptions{Delimiter: ";;"}, Data: payload.Data},
+		"unknown quoting": {Format: "tsv", CSV: &types.CSVOptions{Quoting: "never"}, Data: payload.Data},
+		"multiple sheets": {Format: "csv", Sheets: []types.Sheet{{Name: "A", Data: payload.Data}, {Name: "B", Data: payload.Data}}},
+		"template as csv": {Format: "csv", Template: "invoice"},
+		"lenient mode":    {Format: "csv", Mode: "lenient", Data: payload.Data},
+	}
+	for name, request := range invalid {
+		t.Run("should reject "+name, func(t *testing.T) {
+			if rr := post(t, request, ""); rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestStreamedExport(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	conv := converter.NewConverter()
+
+	// Rows past excelize's 16MB chunk size are spilled to temporary files.
+	notes := strings.Repeat("x", 1000)
+	data := GenerateDataItems(20000)
+	for _, row := range data {
+		row["notes"] = notes
+	}
+	meta := types.MetaData{Columns: []types.ColumnMeta{
+		{Name: "name", Type: "STRING", DefaultVisibility: "hidden"},
+		{Name: "age", Type: "INTEGER"},
+		{Name: "salary", Type: "FLOAT"},
+		{Name: "joined", Type: "DATETIME"},
+		{Name: "notes", Type: "STRING"},
+	}}
+
+	t.Run("should remove temporary files when the export fails", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		t.Setenv("TMPDIR", tmpDir)
+
+		invalid := slices.Clone(data)
+		invalid[len(invalid)-1] = map[string]interface{}{"name": "Name", "age": "old"}
+		err := conv.StreamToExcel(io.Discard, []types.Sheet{{Name: "Sheet1", Data: invalid, Meta: meta}}, types.ExcelOptions{})
+		var conversionErr *types.ConversionError
+		if !errors.As(err, &conversionErr) {
+			t.Fatalf("expected a conversion error, got %v", err)
+		}
+
+		entries, err := os.ReadDir(tmpDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			t.Errorf("temporary file %s was left behind", entry.Name())
+		}
+	})
+
+	t.Run("should write streamed rows with their styles and widths", func(t *testing.T) {
+		var buffer bytes.Buffer
+		rows := &generatedRows{total: len(data), notes: notes}
+		if err := conv.StreamToExcel(&buffer, []types.Sheet{{Name: "Sheet1", Rows: rows, Meta: meta}}, types.ExcelOptions{}); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := excelize.OpenReader(bytes.NewReader(buffer.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		sheetRows, err := f.GetRows("Sheet1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sheetRows) != len(data)+1 {
+			t.Fatalf("expected %d rows, got %d", len(data)+1, len(sheetRows))
+		}
+		expectedRows := map[int][]string{
+			1:     {"name", "age", "salary", "joined", "notes"},
+			2:     {"Name 0", "20", "30000.00", "2022-01-01 15:04", notes},
+			15001: {"Name 14999", "69", "179990.00", "2022-01-27 15:04", notes},
+			20001: {"Name 19999", "69", "229990.00", "2022-01-05 15:04", notes},
+		}
+		for rowNum, expected := range expectedRows {
+			if got := sheetRows[rowNum-1]; !reflect.DeepEqual(got, expected) {
+				t.Errorf("unexpected row %d %v", rowNum, got)
+			}
+		}
+
+		// Hidden columns are written with zero width, which GetColWidth
+		// reports as the default width.
+		sheetXML := ReadZipParts(t, buffer.Bytes())["xl/worksheets/sheet1.xml"]
+		if !strings.Contains(sheetXML, `<col min="1" max="1" width="0" customWidth="1"/>`) {
+			t.Error("expected the hidden column to have zero width")
+		}
+		if strings.Contains(sheetXML, `<col min="2" max="2" width="0"`) {
+			t.Error("expected the visible column to have a width")
+		}
+	})
+
+	t.Run("should keep memory bounded while streaming", func(t *testing.T) {
+		// About 100MB of row data is written, which would stay on the heap
+		// if the rows were buffered.
+		rows := &generatedRows{total: 100000, notes: notes, measure: true}
+		if err := conv.StreamToExcel(io.Discard, []types.Sheet{{Name: "Sheet1", Rows: rows, Meta: meta}}, types.ExcelOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if limit := uint64(64 << 20); rows.peak > limit {
+			t.Errorf("expected the live heap to stay below %d bytes, peaked at %d", limit, rows.peak)
+		}
+	})
+}
+
+// generatedRows streams rows like GenerateDataItems without holding them in
+// memory. With measure, it records the peak live heap while rows are read.
+type generatedRows struct {
+	total   int
+	index   int
+	notes   string
+	measure bool
+	peak    uint64
+}
+
+func (r *generatedRows) Next() (map[string]interface{}, error) {
+	if r.index == r.total {
+		return nil, io.EOF
+	}
+	if r.measure && r.index%10000 == 0 {
+		runtime.GC()
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		r.peak = max(r.peak, stats.HeapAlloc)
+	}
+	row := GenerateDataItem(r.index)
+	row["notes"] = r.notes
+	r.index++
+	return row, nil
+}
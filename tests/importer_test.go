@@ -0,0 +1,452 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jagac/excelify/internal/converter"
+	"github.com/jagac/excelify/internal/server"
+	"github.com/jagac/excelify/internal/types"
+	"github.com/xuri/excelize/v2"
+	"go.uber.org/goleak"
+)
+
+func TestExcelToJsonHandler(t *testing.T) {
+	handler := server.NewHandler(converter.NewConverter())
+
+	t.Run("should emit typed values", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+		f := excelize.NewFile()
+		defer f.Close()
+
+		dateStyle, err := f.NewStyle(&excelize.Style{NumFmt: 14})
+		if err != nil {
+			t.Fatal(err)
+		}
+		elapsedStyle, err := f.NewStyle(&excelize.Style{NumFmt: 46})
+		if err != nil {
+			t.Fatal(err)
+		}
+		SetSheetRows(t, f, "Sheet1", [][]interface{}{
+			{"name", "age", "active", "joined", "note", "took"},
+			{"Name 0", 42, true, time.Date(2022, 1, 15, 0, 0, 0, 0, time.UTC), nil, 1.5},
+		})
+		if err := f.SetCellStyle("Sheet1", "D2", "D2", dateStyle); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.SetCellStyle("Sheet1", "F2", "F2", elapsedStyle); err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.HandleExcelToJson(rr, NewUploadRequest(t, f, map[string]string{"typed": "true"}))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var result []map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		if len(result) != 1 {
+			t.Fatalf("expected 1 row, got %d", len(result))
+		}
+
+		row := result[0]
+		if row["age"] != float64(42) {
+			t.Errorf("expected age 42, got %#v", row["age"])
+		}
+		if row["active"] != true {
+			t.Errorf("expected active true, got %#v", row["active"])
+		}
+		if row["joined"] != "2022-01-15" {
+			t.Errorf("expected joined 2022-01-15, got %#v", row["joined"])
+		}
+		if value, ok := row["note"]; !ok || value != nil {
+			t.Errorf("expected note to be null, got %#v", value)
+		}
+		if row["took"] != "36:00:00" {
+			t.Errorf("expected took 36:00:00, got %#v", row["took"])
+		}
+	})
+	t.Run("should select sheets", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+		f := excelize.NewFile()
+		defer f.Close()
+
+		if _, err := f.NewSheet("Data"); err != nil {
+			t.Fatal(err)
+		}
+		SetSheetRows(t, f, "Sheet1", [][]interface{}{{"title"}, {"Cover"}})
+		SetSheetRows(t, f, "Data", [][]interface{}{{"name"}, {"Name 0"}, {"Name 1"}})
+
+		for _, selector := range []string{"Data", "1"} {
+			rr := httptest.NewRecorder()
+			handler.HandleExcelToJson(rr, NewUploadRequest(t, f, map[string]string{"sheet": selector}))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+			}
+
+			var result []map[string]interface{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			if len(result) != 2 || result[1]["name"] != "Name 1" {
+				t.Errorf("sheet %q: unexpected result %v", selector, result)
+			}
+		}
+
+		rr := httptest.NewRecorder()
+		handler.HandleExcelToJson(rr, NewUploadRequest(t, f, map[string]string{"sheet": "Missing"}))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		rr = httptest.NewRecorder()
+		handler.HandleExcelToJson(rr, NewUploadRequest(t, f, map[string]string{"all_sheets": "true"}))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var sheets map[string][]map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &sheets); err != nil {
+			t.Fatal(err)
+		}
+		if len(sheets["Sheet1"]) != 1 || len(sheets["Data"]) != 2 {
+			t.Errorf("unexpected all sheets result %v", sheets)
+		}
+	})
+	t.Run("should resolve blank, duplicate and missing headers", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+		f := excelize.NewFile()
+		defer f.Close()
+
+		if _, err := f.NewSheet("Empty"); err != nil {
+			t.Fatal(err)
+		}
+		SetSheetRows(t, f, "Sheet1", [][]interface{}{
+			{"name", "", "name"},
+			{"Name 0", "x", "y", "extra"},
+		})
+
+		rr := httptest.NewRecorder()
+		handler.HandleExcelToJson(rr, NewUploadRequest(t, f, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var result []map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]interface{}{"name": "Name 0", "column_B": "x", "name_2": "y", "column_D": "extra"}
+		if len(result) != 1 || len(result[0]) != len(expected) {
+			t.Fatalf("unexpected result %v", result)
+		}
+		for key, value := range expected {
+			if result[0][key] != value {
+				t.Errorf("expected %s to be %v, got %v", key, value, result[0][key])
+			}
+		}
+
+		rr = httptest.NewRecorder()
+		handler.HandleExcelToJson(rr, NewUploadRequest(t, f, map[string]string{"extra_cells": "error"}))
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+		}
+
+		rr = httptest.NewRecorder()
+		handler.HandleExcelToJson(rr, NewUploadRequest(t, f, map[string]string{"sheet": "Empty"}))
+		if rr.Code != http.StatusOK || rr.Body.String() != "[]" {
+			t.Errorf("expected an empty array for an empty sheet, got %d %s", rr.Code, rr.Body.String())
+		}
+	})
+	t.Run("should name extra cells after their column in ragged rows", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+		f := excelize.NewFile()
+		defer f.Close()
+		SetSheetRows(t, f, "Sheet1", [][]interface{}{
+			{"a", "b"},
+			{"1", "2", nil, nil, nil, "F2"},
+			{"3", "4", "C3"},
+			{"5"},
+		})
+
+		rr := httptest.NewRecorder()
+		handler.HandleExcelToJson(rr, NewUploadRequest(t, f, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var result []map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		expected := []map[string]interface{}{
+			{"a": "1", "b": "2", "column_F": "F2"},
+			{"a": "3", "b": "4", "column_C": "C3"},
+			{"a": "5"},
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("expected %v, got %v", expected, result)
+		}
+	})
+	t.Run("should read below title rows", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+		f := excelize.NewFile()
+		defer f.Close()
+
+		SetSheetRows(t, f, "Sheet1", [][]interface{}{
+			{"Quarterly report"},
+			{},
+			{nil, "name", "age", "email"},
+			{nil, "Name 0", 20, "email0@example.com"},
+			{nil, "Name 1", 21, "email1@example.com"},
+			{nil, "Total", 41},
+		})
+
+		cases := map[string]map[string]string{
+			"auto detect": {"header_row": "auto"},
+			"range":       {"range": "B3:D5"},
+		}
+		for name, fields := range cases {
+			rr := httptest.NewRecorder()
+			handler.HandleExcelToJson(rr, NewUploadRequest(t, f, fields))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("%s: expected status code %d, got %d: %s", name, http.StatusOK, rr.Code, rr.Body.String())
+			}
+
+			var result []map[string]interface{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			if len(result) < 2 || len(result[0]) != 3 || result[1]["name"] != "Name 1" || result[1]["email"] != "email1@example.com" {
+				t.Errorf("%s: unexpected result %v", name, result)
+			}
+			if name == "range" && len(result) != 2 {
+				t.Errorf("range: expected 2 rows, got %d", len(result))
+			}
+		}
+
+		rr := httptest.NewRecorder()
+		handler.HandleExcelToJson(rr, NewUploadRequest(t, f, map[string]string{"range": "B3"}))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for an invalid range, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+	t.Run("should round trip labels to keys", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+		buffer, err := converter.NewConverter().ConvertToExcel([]types.Sheet{{
+			Name: "Sheet1",
+			Data: GenerateDataItems(3),
+			Meta: types.MetaData{Columns: []types.ColumnMeta{
+				{Name: "name", Label: "Full Name", Type: "STRING"},
+				{Name: "salary", Label: "Annual Salary (EUR)", Type: "FLOAT"},
+			}},
+		}}, types.ExcelOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := excelize.OpenReader(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		headers, err := f.GetRows("Sheet1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if headers[0][0] != "Full Name" || headers[0][1] != "Annual Salary (EUR)" {
+			t.Fatalf("expected labels as headers, got %v", headers[0])
+		}
+
+		keys := `{"Full Name": "name", "Annual Salary (EUR)": "salary"}`
+		rr := httptest.NewRecorder()
+		handler.HandleExcelToJson(rr, NewUploadRequest(t, f, map[string]string{"keys": keys, "typed": "true"}))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var result []map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		if len(result) != 3 || result[2]["name"] != "Name 2" || result[2]["salary"] != float64(30020) {
+			t.Errorf("unexpected result %v", result)
+		}
+	})
+	t.Run("should unflatten dotted headers", func(t *testing.T) {
+		var data []map[string]interface{}
+		if err := json.Unmarshal([]byte(`[{"id": 1, "customer": {"name": "Ann", "address": {"city": "Oslo"}}, "tags": ["new", "vip"]}]`), &data); err != nil {
+			t.Fatal(err)
+		}
+		buffer, err := converter.NewConverter().ConvertToExcel([]types.Sheet{{
+			Data: data,
+			Meta: types.MetaData{Columns: []types.ColumnMeta{
+				{Name: "id", Type: "INTEGER"},
+				{Name: "customer.name", Type: "STRING"},
+				{Name: "customer.address.city", Type: "STRING"},
+				{Name: "tags[0]", Type: "STRING"},
+				{Name: "tags[1]", Type: "STRING"},
+			}},
+		}}, types.ExcelOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := excelize.OpenReader(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		rr := httptest.NewRecorder()
+		handler.HandleExcelToJson(rr, NewUploadRequest(t, f, map[string]string{"unflatten": "true", "typed": "true"}))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var result []map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		if len(result) != 1 || !reflect.DeepEqual(result[0], data[0]) {
+			t.Errorf("expected %v, got %v", data, result)
+		}
+	})
+	t.Run("should check rows against column meta", func(t *testing.T) {
+		meta := types.MetaData{
+			Columns: []types.ColumnMeta{
+				{Name: "name", Label: "Full Name", Type: "STRING", Required: true},
+				{Name: "age", Type: "INTEGER", Validation: &types.Validation{Min: 18.0, Max: 67.0}},
+				{Name: "joined", Type: "DATE", InputFormats: []string{"2006-01-02 15:04"}},
+				{Name: "team", Type: "STRING", Validation: &types.Validation{Enum: []string{"Sales", "Ops"}}},
+			},
+			EntryRows: 5,
+		}
+		data := GenerateDataItems(3)
+		for _, row := range data {
+			row["team"] = "Sales"
+		}
+		buffer, err := converter.NewConverter().ConvertToExcel([]types.Sheet{{Data: data, Meta: meta}}, types.ExcelOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := excelize.OpenReader(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		rawMeta, err := json.Marshal(meta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler.HandleExcelToJson(rr, NewUploadRequest(t, f, map[string]string{"meta": string(rawMeta)}))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var result []map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]interface{}{"name": "Name 1", "age": float64(21), "joined": "2022-01-02", "team": "Sales"}
+		if len(result) != 3 || !reflect.DeepEqual(result[1], expected) {
+			t.Fatalf("expected 3 rows with %v, got %v", expected, result)
+		}
+
+		SetSheetRows(t, f, "Sheet1", [][]interface{}{
+			{"Full Name", "age", "joined", "team"},
+			{"Name 0", 17, "2022-01-01", "Sales"},
+			{nil, 30, "yesterday", "Marketing"},
+		})
+		rr = httptest.NewRecorder()
+		handler.HandleExcelToJson(rr, NewUploadRequest(t, f, map[string]string{"meta": string(rawMeta)}))
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+		}
+
+		var body struct {
+			Error types.ValidationError `json:"error"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		cells := make([]string, len(body.Error.Errors))
+		for i, cellErr := range body.Error.Errors {
+			cells[i] = cellErr.Cell
+		}
+		if body.Error.Total != 4 || !reflect.DeepEqual(cells, []string{"B2", "A3", "C3", "D3"}) {
+			t.Errorf("unexpected validation errors %+v", body.Error)
+		}
+
+		SetSheetRows(t, f, "Sheet1", [][]interface{}{{"age"}, {30}})
+		rr = httptest.NewRecorder()
+		handler.HandleExcelToJson(rr, NewUploadRequest(t, f, map[string]string{"meta": string(rawMeta)}))
+		if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), `"missing_columns":[{"sheet":"Sheet1","column":"name"}]`) {
+			t.Errorf("expected the missing name column to be reported, got %d: %s", rr.Code, rr.Body.String())
+		}
+	})
+	t.Run("should round trip every column type", func(t *testing.T) {
+		meta := types.MetaData{Columns: []types.ColumnMeta{
+			{Name: "name", Type: "STRING"},
+			{Name: "age", Type: "INTEGER"},
+			{Name: "salary", Type: "FLOAT"},
+			{Name: "joined", Type: "DATETIME"},
+			{Name: "share", Type: "PERCENTAGE"},
+			{Name: "active", Type: "BOOLEAN"},
+			{Name: "born", Type: "DATE"},
+			{Name: "start", Type: "TIME"},
+			{Name: "price", Type: "CURRENCY", Currency: "EUR"},
+			{Name: "took", Type: "DURATION"},
+			{Name: "double", Type: "FORMULA", Formula: "=B{row}*2"},
+		}}
+		data := []map[string]interface{}{
+			{"name": "Name 0", "age": 30, "salary": 55000.5, "joined": "2022-01-15 15:04", "share": 0.25, "active": true,
+				"born": "1990-02-03", "start": "08:30", "price": 9.99, "took": "PT36H30M"},
+		}
+		buffer, err := converter.NewConverter().ConvertToExcel([]types.Sheet{{Data: data, Meta: meta}}, types.ExcelOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := excelize.OpenReader(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		rawMeta, err := json.Marshal(meta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler.HandleExcelToJson(rr, NewUploadRequest(t, f, map[string]string{"meta": string(rawMeta)}))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var result []map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		// Formula cells have no cached value until Excel calculates them.
+		expected := map[string]interface{}{
+			"name": "Name 0", "age": float64(30), "salary": 55000.5, "joined": "2022-01-15T15:04:00", "share": 0.25, "active": true,
+			"born": "1990-02-03", "start": "08:30:00", "price": 9.99, "took": float64(131400), "double": nil,
+		}
+		if len(result) != 1 || !reflect.DeepEqual(result[0], expected) {
+			t.Errorf("expected %v, got %v", expected, result)
+		}
+	})
+}
@@ -0,0 +1,356 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jagac/excelify/internal/converter"
+	"github.com/jagac/excelify/internal/server"
+	"github.com/jagac/excelify/internal/templates"
+	"github.com/jagac/excelify/internal/types"
+	"github.com/xuri/excelize/v2"
+	"go.uber.org/goleak"
+)
+
+// NewInvoiceTemplate returns a template with placeholders, a named cell and
+// a repeating row of invoice lines followed by a total.
+func NewInvoiceTemplate(t *testing.T) *excelize.File {
+	t.Helper()
+	f := excelize.NewFile()
+	SetSheetRows(t, f, "Sheet1", [][]interface{}{
+		{"Invoice for {{ customer.name }}"},
+		{"Total due", "{{total}}"},
+		{"Date"},
+		{},
+		{"SKU", "Qty", "Price", "Amount"},
+		{"{{items[].sku}}", "{{items[].qty}}", "{{items[].price}}"},
+		{},
+		{"Total"},
+		{"{{discounts[].name}}"},
+		{"Thanks {{customer.name}}, tags: {{tags}}"},
+	})
+	if err := f.SetCellFormula("Sheet1", "D6", "B6*C6"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetCellFormula("Sheet1", "D8", "SUM(D6:D7)"); err != nil {
+		t.Fatal(err)
+	}
+	style, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetCellStyle("Sheet1", "A6", "A6", style); err != nil {
+		t.Fatal(err)
+	}
+	dv := excelize.NewDataValidation(true)
+	dv.Sqref = "C6"
+	if err := dv.SetRange(0, 1000, excelize.DataValidationTypeDecimal, excelize.DataValidationOperatorBetween); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.AddDataValidation("Sheet1", dv); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetDefinedName(&excelize.DefinedName{Name: "invoice.date", RefersTo: "Sheet1!$B$3"}); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestTemplateExport(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	dir := t.TempDir()
+	template := NewInvoiceTemplate(t)
+	defer template.Close()
+	if err := template.SaveAs(filepath.Join(dir, "invoice.xlsx")); err != nil {
+		t.Fatal(err)
+	}
+	handler := server.NewHandler(converter.NewConverter(), server.WithTemplates(templates.Dir(dir)))
+
+	values := map[string]interface{}{
+		"customer": map[string]interface{}{"name": "ACME"},
+		"total":    1234.5,
+		"invoice":  map[string]interface{}{"date": "2024-05-01"},
+		"items": []interface{}{
+			map[string]interface{}{"sku": "A-1", "qty": 1, "price": 10},
+			map[string]interface{}{"sku": "B-2", "qty": 2, "price": 20},
+			map[string]interface{}{"sku": "C-3", "qty": 3, "price": 30},
+		},
+		"tags": []interface{}{"new", "vip"},
+	}
+
+	check := func(t *testing.T, rr *httptest.ResponseRecorder) {
+		t.Helper()
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		f, err := excelize.OpenReader(rr.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		expected := map[string]string{
+			"A1":  "Invoice for ACME",
+			"B2":  "1234.5",
+			"B3":  "2024-05-01",
+			"A6":  "A-1",
+			"A7":  "B-2",
+			"B8":  "3",
+			"C8":  "30",
+			"A10": "Total",
+			"A11": "Thanks ACME, tags: new, vip",
+		}
+		for cell, want := range expected {
+			if got, _ := f.GetCellValue("Sheet1", cell); got != want {
+				t.Errorf("expected %s to be %q, got %q", cell, want, got)
+			}
+		}
+		if cellType, _ := f.GetCellType("Sheet1", "B7"); cellType != excelize.CellTypeNumber && cellType != excelize.CellTypeUnset {
+			t.Errorf("expected quantities to be numbers, got cell type %v", cellType)
+		}
+		for cell, want := range map[string]string{"D7": "B7*C7", "D8": "B8*C8", "D10": "SUM(D6:D9)"} {
+			if got, _ := f.GetCellFormula("Sheet1", cell); got != want {
+				t.Errorf("expected the formula of %s to be %q, got %q", cell, want, got)
+			}
+		}
+		if result, _ := f.CalcCellValue("Sheet1", "D10"); result != "140" {
+			t.Errorf("expected the total to be 140, got %q", result)
+		}
+
+		templateStyle, _ := f.GetCellStyle("Sheet1", "A6")
+		if copyStyle, _ := f.GetCellStyle("Sheet1", "A8"); copyStyle != templateStyle || copyStyle == 0 {
+			t.Errorf("expected repeated rows to share the style %d, got %d", templateStyle, copyStyle)
+		}
+		validations, err := f.GetDataValidations("Sheet1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(validations) != 1 || validations[0].Sqref != "C6:C8" {
+			t.Errorf("expected the validation to cover C6:C8, got %+v", validations)
+		}
+	}
+
+	t.Run("should fill a template from the template directory", func(t *testing.T) {
+		check(t, PostExcelRequest(t, handler, types.RequestJson{Filename: "invoice.xlsx", Template: "invoice", Values: values}))
+	})
+
+	t.Run("should fill an uploaded template", func(t *testing.T) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		if err := writer.WriteField("values", `{"customer": {"name": "ACME"}, "total": 1234.5, "invoice": {"date": "2024-05-01"}, "tags": ["new", "vip"],
+			"items": [{"sku": "A-1", "qty": 1, "price": 10}, {"sku": "B-2", "qty": 2, "price": 20}, {"sku": "C-3", "qty": 3, "price": 30}]}`); err != nil {
+			t.Fatal(err)
+		}
+		part, err := writer.CreateFormFile("template", "invoice.xlsx")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := template.Write(part); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest("POST", "/api/v1/conversions/to-excel", &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rr := httptest.NewRecorder()
+		handler.HandleJsonToExcel(rr, req)
+		check(t, rr)
+	})
+
+	t.Run("should reject unknown templates", func(t *testing.T) {
+		for _, name := range []string{"missing", "../invoice"} {
+			rr := PostExcelRequest(t, handler, types.RequestJson{Template: name, Values: values})
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d for %q, got %d", http.StatusBadRequest, name, rr.Code)
+			}
+		}
+	})
+
+	t.Run("should reject rows repeating two arrays", func(t *testing.T) {
+		f := excelize.NewFile()
+		defer f.Close()
+		SetSheetRows(t, f, "Sheet1", [][]interface{}{{"{{items[].sku}}", "{{tags[]}}"}})
+		if err := f.SaveAs(filepath.Join(dir, "broken.xlsx")); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(filepath.Join(dir, "broken.xlsx"))
+
+		rr := PostExcelRequest(t, handler, types.RequestJson{Template: "broken", Values: values})
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
+
+func TestTemplateRegistry(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	handler := server.NewHandler(converter.NewConverter(), server.WithTemplateRegistry(templates.NewRegistry(t.TempDir())))
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/templates", handler.HandleListTemplates)
+	mux.HandleFunc("POST /api/v1/templates/{name}", handler.HandleUploadTemplate)
+	mux.HandleFunc("GET /api/v1/templates/{ref}", handler.HandleGetTemplate)
+	mux.HandleFunc("DELETE /api/v1/templates/{ref}", handler.HandleDeleteTemplate)
+
+	serve := func(method, path string, body io.Reader, contentType string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+	upload := func(name string, write func(io.Writer) error) *httptest.ResponseRecorder {
+		t.Helper()
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, err := writer.CreateFormFile("template", name+".xlsx")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := write(part); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return serve("POST", "/api/v1/templates/"+name, &body, writer.FormDataContentType())
+	}
+	uploadTemplate := func(title string) types.TemplateVersion {
+		t.Helper()
+		f := excelize.NewFile()
+		defer f.Close()
+		SetSheetRows(t, f, "Sheet1", [][]interface{}{{title + " {{customer.name}}"}})
+		rr := upload("invoice", func(w io.Writer) error { return f.Write(w) })
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+		}
+		var version types.TemplateVersion
+		if err := json.Unmarshal(rr.Body.Bytes(), &version); err != nil {
+			t.Fatal(err)
+		}
+		return version
+	}
+	fill := func(ref string) string {
+		t.Helper()
+		rr := PostExcelRequest(t, handler, types.RequestJson{Template: ref, Values: map[string]interface{}{"customer": map[string]interface{}{"name": "ACME"}}})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d for %q, got %d: %s", http.StatusOK, ref, rr.Code, rr.Body.String())
+		}
+		f, err := excelize.OpenReader(rr.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		value, _ := f.GetCellValue("Sheet1", "A1")
+		return value
+	}
+
+	t.Run("should store templates under increasing versions", func(t *testing.T) {
+		for i, title := range []string{"Invoice for", "Bill for"} {
+			if version := uploadTemplate(title); version.Name != "invoice" || version.Version != i+1 || version.Size == 0 {
+				t.Errorf("expected version %d of invoice, got %+v", i+1, version)
+			}
+		}
+
+		rr := serve("GET", "/api/v1/templates", nil, "")
+		var infos []types.TemplateInfo
+		if err := json.Unmarshal(rr.Body.Bytes(), &infos); err != nil {
+			t.Fatal(err)
+		}
+		if len(infos) != 1 || infos[0].Name != "invoice" || infos[0].Latest != 2 || len(infos[0].Versions) != 2 {
+			t.Errorf("expected invoice with 2 versions, got %+v", infos)
+		}
+	})
+
+	t.Run("should fill templates by reference", func(t *testing.T) {
+		for ref, want := range map[string]string{"invoice@1": "Invoice for ACME", "invoice@2": "Bill for ACME", "invoice": "Bill for ACME"} {
+			if got := fill(ref); got != want {
+				t.Errorf("expected %q to fill A1 with %q, got %q", ref, want, got)
+			}
+		}
+	})
+
+	t.Run("should download a version", func(t *testing.T) {
+		rr := serve("GET", "/api/v1/templates/invoice@1", nil, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+		f, err := excelize.OpenReader(rr.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if value, _ := f.GetCellValue("Sheet1", "A1"); value != "Invoice for {{customer.name}}" {
+			t.Errorf("expected the first version, got %q", value)
+		}
+	})
+
+	t.Run("should not reuse deleted versions", func(t *testing.T) {
+		if rr := serve("DELETE", "/api/v1/templates/invoice@2", nil, ""); rr.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d, got %d", http.StatusNoContent, rr.Code)
+		}
+		if got := fill("invoice"); got != "Invoice for ACME" {
+			t.Errorf("expected the latest version to be 1 again, got %q", got)
+		}
+		if version := uploadTemplate("Receipt for"); version.Version != 3 {
+			t.Errorf("expected version 3, got %d", version.Version)
+		}
+	})
+
+	t.Run("should delete every version of a template", func(t *testing.T) {
+		if rr := serve("DELETE", "/api/v1/templates/invoice", nil, ""); rr.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d, got %d", http.StatusNoContent, rr.Code)
+		}
+		for _, req := range [][2]string{{"GET", "invoice"}, {"GET", "invoice@1"}, {"DELETE", "invoice"}, {"GET", "invoice@x"}} {
+			if rr := serve(req[0], "/api/v1/templates/"+req[1], nil, ""); rr.Code != http.StatusNotFound {
+				t.Errorf("expected status code %d for %s %s, got %d", http.StatusNotFound, req[0], req[1], rr.Code)
+			}
+		}
+		if rr := PostExcelRequest(t, handler, types.RequestJson{Template: "invoice@1"}); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should not reuse versions of a deleted template", func(t *testing.T) {
+		if version := uploadTemplate("Quote for"); version.Version != 4 {
+			t.Errorf("expected version 4, got %d", version.Version)
+		}
+		if rr := serve("GET", "/api/v1/templates/invoice@3", nil, ""); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d for a deleted version, got %d", http.StatusNotFound, rr.Code)
+		}
+		if got := fill("invoice"); got != "Quote for ACME" {
+			t.Errorf("expected the new upload to be the latest version, got %q", got)
+		}
+	})
+
+	t.Run("should reject invalid uploads", func(t *testing.T) {
+		if rr := upload("notes", func(w io.Writer) error { _, err := io.WriteString(w, "not a workbook"); return err }); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for a file that is not XLSX, got %d", http.StatusBadRequest, rr.Code)
+		}
+		f := excelize.NewFile()
+		defer f.Close()
+		if rr := upload("bad@name", func(w io.Writer) error { return f.Write(w) }); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for an invalid name, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}